@@ -0,0 +1,64 @@
+package main
+
+import (
+	urlpkg "net/url"
+	"strings"
+)
+
+// default ports of schemes, which canonical URLs leave out
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// CanonOptions customizes URL canonicalization beyond what is always done.
+type CanonOptions struct {
+	StripTracking bool // drop utm_* query parameters
+	StripFragment bool // drop fragment
+}
+
+// CanonicalURL returns the canonical form of url, so that URLs pointing to the same resource share it. It
+// lowercases scheme and host, drops the default port, trims trailing slashes of non-root path, sorts the query
+// parameters and gives an empty HTTP(S) path "/". url is returned as is if it can't be parsed.
+func CanonicalURL(url string, o CanonOptions) string {
+	url = strings.TrimSpace(url)
+	u, err := urlpkg.Parse(url)
+	if err != nil || u.Opaque != "" {
+		return url
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if strings.Contains(host, ":") {
+		// IPv6 literal
+		host = "[" + host + "]"
+	}
+	if port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	u.Host = host
+	if len(u.Path) > 1 {
+		// trailing slashes of non-root path rarely tell resources apart
+		u.Path, u.RawPath = strings.TrimRight(u.Path, "/"), strings.TrimRight(u.RawPath, "/")
+	}
+	if _, ok := defaultPorts[u.Scheme]; ok && u.Path == "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		q, err := urlpkg.ParseQuery(u.RawQuery)
+		if err == nil {
+			if o.StripTracking {
+				for k := range q {
+					if strings.HasPrefix(strings.ToLower(k), "utm_") {
+						delete(q, k)
+					}
+				}
+			}
+			// Encode sorts by key
+			u.RawQuery = q.Encode()
+		}
+	}
+	if o.StripFragment {
+		u.Fragment = ""
+	}
+	return u.String()
+}
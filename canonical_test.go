@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tcs := []struct {
+		name string
+		in   string
+		o    CanonOptions
+		exp  string
+	}{
+		{"AsIs", "https://foo.io/bar?a=1#x", CanonOptions{}, "https://foo.io/bar?a=1#x"},
+		{"Case", "HTTPS://Foo.IO/Bar", CanonOptions{}, "https://foo.io/Bar"},
+		{"DefaultPort", "http://foo.io:80/a", CanonOptions{}, "http://foo.io/a"},
+		{"DefaultHTTPSPort", "https://foo.io:443", CanonOptions{}, "https://foo.io/"},
+		{"OtherPort", "https://foo.io:8443/", CanonOptions{}, "https://foo.io:8443/"},
+		{"IPv6", "http://[::1]:80/", CanonOptions{}, "http://[::1]/"},
+		{"EmptyPath", "https://foo.io", CanonOptions{}, "https://foo.io/"},
+		{"TrailingSlash", "https://foo.io/a/b//?q=1", CanonOptions{}, "https://foo.io/a/b?q=1"},
+		{"RootSlash", "https://foo.io//", CanonOptions{}, "https://foo.io/"},
+		{"SortQuery", "https://foo.io/?b=2&a=1&b=1", CanonOptions{}, "https://foo.io/?a=1&b=2&b=1"},
+		{"KeepTracking", "https://foo.io/?utm_source=x&a=1", CanonOptions{}, "https://foo.io/?a=1&utm_source=x"},
+		{
+			"StripTracking",
+			"https://foo.io/?utm_source=x&a=1&UTM_Medium=y",
+			CanonOptions{StripTracking: true},
+			"https://foo.io/?a=1",
+		},
+		{"StripTrackingOnly", "https://foo.io/?utm_source=x", CanonOptions{StripTracking: true}, "https://foo.io/"},
+		{"StripFragment", "https://foo.io/a#top", CanonOptions{StripFragment: true}, "https://foo.io/a"},
+		{"Opaque", "mailto:Foo@Bar.io", CanonOptions{}, "mailto:Foo@Bar.io"},
+		{"Unparsable", " http://foo.io/%zz ", CanonOptions{}, "http://foo.io/%zz"},
+	}
+	for _, c := range tcs {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.exp, CanonicalURL(c.in, c.o))
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf16"
+
+	"go.uber.org/zap"
+)
+
+const (
+	chromeTypeURL    = "url"
+	chromeTypeFolder = "folder"
+	// seconds between Windows epoch(1601-01-01), which Chrome timestamps are based on, and Unix epoch
+	windowsToUnixEpochSeconds = 11644473600
+)
+
+// the order in which Chrome computes bookmark file checksum over roots
+var chromeRoots = []string{"bookmark_bar", "other", "synced"}
+
+// ChromeWalker walks the "Bookmarks" JSON file of Chrome profile, which Chromium-based browsers like Edge share.
+type ChromeWalker struct {
+	chanWalker
+	r     io.Reader
+	raw   map[string]json.RawMessage // the whole bookmark file as is
+	roots map[string]*chromeNode
+}
+
+// chromeNode is a bookmark or folder in Chrome bookmark file.
+type chromeNode struct {
+	Children  []*chromeNode     `json:"children"`
+	DateAdded string            `json:"date_added"`
+	GUID      string            `json:"guid"`
+	ID        string            `json:"id"`
+	MetaInfo  map[string]string `json:"meta_info"`
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	URL       string            `json:"url"`
+	// raw holds all the fields as is, so that fields unknown to us survive cleaning
+	raw map[string]json.RawMessage
+	bmk *Bookmark
+}
+
+func (n *chromeNode) UnmarshalJSON(b []byte) error {
+	type plain chromeNode
+	if err := json.Unmarshal(b, (*plain)(n)); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &n.raw)
+}
+
+// MarshalJSON encodes n with its dead bookmarks removed.
+func (n *chromeNode) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(n.raw))
+	for k, v := range n.raw {
+		m[k] = v
+	}
+	if n.Type == chromeTypeFolder {
+		m["children"] = n.aliveChildren()
+	}
+	return marshalJSON(m, "")
+}
+
+func (n *chromeNode) aliveChildren() []*chromeNode {
+	children := make([]*chromeNode, 0, len(n.Children))
+	for _, c := range n.Children {
+		if c.Type == chromeTypeURL && (c.bmk == nil || c.bmk.Status != Alive) {
+			continue
+		}
+		children = append(children, c)
+	}
+	return children
+}
+
+func NewChromeWalker(r io.Reader, log *zap.SugaredLogger) *ChromeWalker {
+	w := &ChromeWalker{r: r}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *ChromeWalker) walk() {
+	defer close(w.wchan)
+	if err := w.decode(); err != nil {
+		w.send(&Result{nil, err})
+		return
+	}
+	for _, name := range w.rootNames() {
+		if !w.walkNode(w.roots[name], nil) {
+			return
+		}
+	}
+}
+
+func (w *ChromeWalker) decode() error {
+	if err := json.NewDecoder(w.r).Decode(&w.raw); err != nil {
+		return err
+	}
+	var roots map[string]json.RawMessage
+	if err := json.Unmarshal(w.raw["roots"], &roots); err != nil {
+		return fmt.Errorf("invalid bookmark roots: %w", err)
+	}
+	w.roots = make(map[string]*chromeNode, len(roots))
+	for name, v := range roots {
+		n := &chromeNode{}
+		// there are non-folder entries like sync_transaction_version in roots, which we simply leave untouched
+		if err := json.Unmarshal(v, n); err != nil || n.Type != chromeTypeFolder {
+			continue
+		}
+		w.roots[name] = n
+	}
+	return nil
+}
+
+// rootNames returns names of walked roots, in the order Chrome computes checksum over them followed by the
+// others sorted by name.
+func (w *ChromeWalker) rootNames() []string {
+	names := make([]string, 0, len(w.roots))
+	for _, name := range chromeRoots {
+		if _, ok := w.roots[name]; ok {
+			names = append(names, name)
+		}
+	}
+	var others []string
+	for name := range w.roots {
+		if !contains(chromeRoots, name) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// walkNode sends n as bookmark if it is a URL node, or walks its children if it is a folder node. The bookmark
+// is kept on n, so that WriteCleaned can tell whether n survived washing. It returns false if walker is stopped.
+func (w *ChromeWalker) walkNode(n *chromeNode, folder []string) bool {
+	switch n.Type {
+	case chromeTypeURL:
+		n.bmk = &Bookmark{URL: n.URL, Title: n.Name, AddDate: chromeTime(n.DateAdded), Folder: folder}
+		w.log.Infow("created bookmark", "bookmark", n.bmk)
+		return w.send(&Result{n.bmk, nil})
+	case chromeTypeFolder:
+		path := make([]string, len(folder), len(folder)+1)
+		copy(path, folder)
+		path = append(path, n.Name)
+		for _, c := range n.Children {
+			if !w.walkNode(c, path) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// WriteCleaned writes the alive bookmarks walked by w as a Chrome bookmark file, with its checksum recomputed
+// so that Chrome accepts it.
+func (w *ChromeWalker) WriteCleaned(out io.Writer) error {
+	roots := map[string]interface{}{}
+	var rootsRaw map[string]json.RawMessage
+	if err := json.Unmarshal(w.raw["roots"], &rootsRaw); err != nil {
+		return err
+	}
+	for name, v := range rootsRaw {
+		if n, ok := w.roots[name]; ok {
+			roots[name] = n
+		} else {
+			roots[name] = v
+		}
+	}
+	doc := map[string]interface{}{}
+	for k, v := range w.raw {
+		doc[k] = v
+	}
+	doc["roots"] = roots
+	doc["checksum"] = w.checksum()
+	b, err := marshalJSON(doc, "   ")
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(b)
+	return err
+}
+
+// checksum computes the checksum of cleaned bookmarks the same way Chrome does, which is the MD5 digest over
+// id, title and type of each node(and URL of bookmarks) in depth-first order.
+func (w *ChromeWalker) checksum() string {
+	h := md5.New()
+	var update func(n *chromeNode)
+	update = func(n *chromeNode) {
+		io.WriteString(h, n.ID)
+		// Chrome digests title in UTF-16
+		binary.Write(h, binary.LittleEndian, utf16.Encode([]rune(n.Name)))
+		io.WriteString(h, n.Type)
+		if n.Type == chromeTypeURL {
+			io.WriteString(h, n.URL)
+			return
+		}
+		for _, c := range n.aliveChildren() {
+			update(c)
+		}
+	}
+	for _, name := range chromeRoots {
+		if n, ok := w.roots[name]; ok {
+			update(n)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chromeTime converts timestamp in Chrome bookmark file, which is microseconds since Windows epoch, to time.
+func chromeTime(ts string) time.Time {
+	us, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || us == 0 {
+		return time.Time{}
+	}
+	return time.Unix(us/1e6-windowsToUnixEpochSeconds, (us%1e6)*1e3)
+}
+
+// marshalJSON encodes v as JSON without escaping HTML characters, as bookmark URLs are full of them.
+func marshalJSON(v interface{}, indent string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const chromeBookmarks = `{
+   "checksum": "d41d8cd98f00b204e9800998ecf8427e",
+   "roots": {
+      "bookmark_bar": {
+         "children": [ {
+            "date_added": "13227534697000000",
+            "guid": "0c2e6b5e-6b1c-4fd0-8f6e-0a4e4e0b3a51",
+            "id": "5",
+            "meta_info": {
+               "last_visited_desktop": "13240000000000000"
+            },
+            "name": "Bar",
+            "type": "url",
+            "url": "https://bar.io/?a=1&b=2"
+         }, {
+            "children": [ {
+               "date_added": "13227534697123456",
+               "guid": "7a4f0a43-5bd4-4b4d-a6ad-8b6e5cb1dc9a",
+               "id": "7",
+               "name": "Qux ☃",
+               "type": "url",
+               "url": "https://qux.io/"
+            } ],
+            "date_added": "13227534690000000",
+            "date_modified": "13227534697123456",
+            "guid": "3b0f6a4f-63f3-4d4e-bd16-6a3f1f0ad2c5",
+            "id": "6",
+            "name": "BarDir",
+            "type": "folder"
+         } ],
+         "date_added": "13227534600000000",
+         "date_modified": "13227534697000000",
+         "guid": "00000000-0000-4000-a000-000000000002",
+         "id": "1",
+         "name": "Bookmarks bar",
+         "type": "folder"
+      },
+      "other": {
+         "children": [ {
+            "date_added": "13227534697000000",
+            "guid": "e1f5c0d4-7d0f-4d55-9a5e-2c5d0c6f1f7e",
+            "id": "8",
+            "name": "Bee",
+            "type": "url",
+            "url": "https://bee.io/"
+         } ],
+         "date_added": "13227534600000000",
+         "date_modified": "0",
+         "guid": "00000000-0000-4000-a000-000000000003",
+         "id": "2",
+         "name": "Other bookmarks",
+         "type": "folder"
+      },
+      "synced": {
+         "children": [  ],
+         "date_added": "13227534600000000",
+         "date_modified": "0",
+         "guid": "00000000-0000-4000-a000-000000000004",
+         "id": "3",
+         "name": "Mobile bookmarks",
+         "type": "folder"
+      }
+   },
+   "version": 1
+}
+`
+
+func TestChromeWalker(t *testing.T) {
+	w := NewChromeWalker(strings.NewReader(chromeBookmarks), genTstLogger())
+	bs := []*Bookmark{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		bs = append(bs, b)
+	}
+	// 13227534697 - 11644473600 = 1583061097
+	assert.Equal(t, []*Bookmark{
+		{
+			URL:     "https://bar.io/?a=1&b=2",
+			Title:   "Bar",
+			AddDate: time.Unix(1583061097, 0),
+			Folder:  []string{"Bookmarks bar"},
+		},
+		{
+			URL:     "https://qux.io/",
+			Title:   "Qux ☃",
+			AddDate: time.Unix(1583061097, 123456000),
+			Folder:  []string{"Bookmarks bar", "BarDir"},
+		},
+		{
+			URL:     "https://bee.io/",
+			Title:   "Bee",
+			AddDate: time.Unix(1583061097, 0),
+			Folder:  []string{"Other bookmarks"},
+		},
+	}, bs)
+}
+
+func TestChromeWalker_Malformed(t *testing.T) {
+	for _, in := range []string{`{"roots": `, `{"roots": []}`} {
+		w := NewChromeWalker(strings.NewReader(in), genTstLogger())
+		b, err := w.Next(context.Background())
+		assert.Nil(t, b)
+		assert.NotNil(t, err)
+		assert.NotEqual(t, io.EOF, err)
+		_, err = w.Next(context.Background())
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestChromeWalker_WriteCleaned(t *testing.T) {
+	w := NewChromeWalker(strings.NewReader(chromeBookmarks), genTstLogger())
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if b.URL != "https://qux.io/" {
+			b.Status = Alive
+		}
+	}
+	out := &bytes.Buffer{}
+	assert.Nil(t, w.WriteCleaned(out))
+	assert.Contains(t, out.String(), `"url": "https://bar.io/?a=1&b=2"`, "URL should not have been escaped")
+
+	var cleaned map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &cleaned))
+	// MD5 over "1", "Bookmarks bar", "folder", "5", "Bar", "url", "https://bar.io/?a=1&b=2", "6", "BarDir",
+	// "folder", "2", "Other bookmarks", "folder", "8", "Bee", "url", "https://bee.io/", "3", "Mobile bookmarks",
+	// "folder" with titles in UTF-16LE
+	assert.Equal(t, "cd7c4350a00c641e1c95deb5294a576e", cleaned["checksum"])
+	assert.Equal(t, 1.0, cleaned["version"])
+	bar := cleaned["roots"].(map[string]interface{})["bookmark_bar"].(map[string]interface{})
+	children := bar["children"].([]interface{})
+	assert.Len(t, children, 2)
+	assert.Equal(t, map[string]interface{}{"last_visited_desktop": "13240000000000000"},
+		children[0].(map[string]interface{})["meta_info"])
+	barDir := children[1].(map[string]interface{})
+	assert.Equal(t, "BarDir", barDir["name"])
+	assert.Equal(t, "3b0f6a4f-63f3-4d4e-bd16-6a3f1f0ad2c5", barDir["guid"])
+	assert.Empty(t, barDir["children"])
+}
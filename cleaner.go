@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Cleaner writes a cleaned copy of the bookmarks it walked, i.e. one holding only the alive bookmarks, in the
+// same format and structure as the input. It should be called only after the walk is done.
+type Cleaner interface {
+	WriteCleaned(w io.Writer) error
+}
+
+const netscapeHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+`
+
+// WriteCleaned writes the alive bookmarks walked by w in Netscape Bookmark File Format, which both Chrome and
+// Firefox can import from.
+func (w *NetscapeWalker) WriteCleaned(out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	title := w.root.Title
+	if title == "" {
+		title = "Bookmarks"
+	}
+	fmt.Fprint(bw, netscapeHeader)
+	fmt.Fprintf(bw, "<TITLE>%s</TITLE>\n<H1>%s</H1>\n", html.EscapeString(title), html.EscapeString(title))
+	writeNetscapeList(bw, w.root.Children, 0)
+	return bw.Flush()
+}
+
+// writeNetscapeList writes nodes as a <DL> list at given indentation depth.
+func writeNetscapeList(w io.Writer, nodes []*Node, depth int) {
+	indent := strings.Repeat("    ", depth)
+	fmt.Fprintf(w, "%s<DL><p>\n", indent)
+	for _, n := range nodes {
+		if b := n.Bookmark; b != nil {
+			if b.Status != Alive {
+				continue
+			}
+			fmt.Fprintf(w, "%s    <DT><A%s>%s</A>\n", indent, netscapeAttrs(b.Attr), html.EscapeString(b.Title))
+			writeNetscapeDesc(w, b.Description, indent)
+			continue
+		}
+		fmt.Fprintf(w, "%s    <DT><H3%s>%s</H3>\n", indent, netscapeAttrs(n.Attr), html.EscapeString(n.Title))
+		writeNetscapeDesc(w, n.Description, indent)
+		writeNetscapeList(w, n.Children, depth+1)
+	}
+	fmt.Fprintf(w, "%s</DL><p>\n", indent)
+}
+
+func writeNetscapeDesc(w io.Writer, desc, indent string) {
+	if desc != "" {
+		fmt.Fprintf(w, "%s    <DD>%s\n", indent, html.EscapeString(desc))
+	}
+}
+
+func netscapeAttrs(attr []html.Attribute) string {
+	sb := &strings.Builder{}
+	for _, a := range attr {
+		fmt.Fprintf(sb, ` %s="%s"`, strings.ToUpper(a.Key), html.EscapeString(a.Val))
+	}
+	return sb.String()
+}
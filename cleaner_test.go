@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetscapeWalker_WriteCleaned(t *testing.T) {
+	in := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1512790922" LAST_MODIFIED="1588537285" PERSONAL_TOOLBAR_FOLDER="true">FooDir</H3>
+    <DL><p>
+        <DT><A HREF="https://bar.io/" ADD_DATE="1515361177" ICON="data:image/png;base64,blah==">Bar</A>
+        <DT><H3 ADD_DATE="1515126229" LAST_MODIFIED="1592885828">Bar &amp; Dir</H3>
+        <DD>dir of &lt;bar&gt;
+        <DL><p>
+            <DT><A HREF="https://qux.io/?a=1&amp;b=2" ADD_DATE="1515361177" TAGS="q,x">Qux</A>
+            <DD>about qux
+            <DT><A HREF="https://dead.io/" ADD_DATE="1515361177">Dead</A>
+            <DD>about dead
+        </DL><p>
+        <DT><A HREF="https://bee.io/" ADD_DATE="1515361173" LAST_MODIFIED="1515361174">Bee</A>
+    </DL><p>
+    <DT><A HREF="https://unknown.io/" ADD_DATE="1515361173">Unknown</A>
+</DL><p>
+`
+	exp := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1512790922" LAST_MODIFIED="1588537285" PERSONAL_TOOLBAR_FOLDER="true">FooDir</H3>
+    <DL><p>
+        <DT><A HREF="https://bar.io/" ADD_DATE="1515361177" ICON="data:image/png;base64,blah==">Bar</A>
+        <DT><H3 ADD_DATE="1515126229" LAST_MODIFIED="1592885828">Bar &amp; Dir</H3>
+        <DD>dir of &lt;bar&gt;
+        <DL><p>
+            <DT><A HREF="https://qux.io/?a=1&amp;b=2" ADD_DATE="1515361177" TAGS="q,x">Qux</A>
+            <DD>about qux
+        </DL><p>
+        <DT><A HREF="https://bee.io/" ADD_DATE="1515361173" LAST_MODIFIED="1515361174">Bee</A>
+    </DL><p>
+</DL><p>
+`
+	statuses := map[string]PingStatus{
+		"https://bar.io/":         Alive,
+		"https://qux.io/?a=1&b=2": Alive,
+		"https://dead.io/":        Dead,
+		"https://bee.io/":         Alive,
+		"https://unknown.io/":     Unknown,
+	}
+	w := NewNetscapeWalker(bytes.NewReader([]byte(in)), genTstLogger())
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		b.Status = statuses[b.URL]
+	}
+	out := &bytes.Buffer{}
+	assert.Nil(t, w.WriteCleaned(out))
+	assert.Equal(t, exp, out.String())
+}
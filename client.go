@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ClientOptions customizes the HTTP client pinging URLs. Zero timeouts mean no timeout.
+type ClientOptions struct {
+	Timeout       time.Duration // timeout of a request as a whole, including redirects
+	DialTimeout   time.Duration // timeout of establishing connection, including DNS lookup
+	TLSTimeout    time.Duration // timeout of TLS handshake
+	HeaderTimeout time.Duration // timeout of waiting for response header once request is sent
+	Resolver      string        // address of DNS server to look up hosts with instead of system's, e.g. 1.1.1.1:53
+}
+
+// DefaultClientOptions keeps a hung server from stalling pings for long.
+var DefaultClientOptions = ClientOptions{
+	Timeout:       30 * time.Second,
+	DialTimeout:   10 * time.Second,
+	TLSTimeout:    10 * time.Second,
+	HeaderTimeout: 20 * time.Second,
+}
+
+// NewHTTPClient returns a new *http.Client customized by o.
+func NewHTTPClient(o ClientOptions) *http.Client {
+	dialer := &net.Dialer{Timeout: o.DialTimeout, KeepAlive: 30 * time.Second}
+	if o.Resolver != "" {
+		dialer.Resolver = newResolver(o.Resolver, o.DialTimeout)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = o.TLSTimeout
+	transport.ResponseHeaderTimeout = o.HeaderTimeout
+	return &http.Client{Transport: transport, Timeout: o.Timeout}
+}
+
+// newResolver returns a resolver looking up hosts with DNS server at addr. Port 53 is assumed if addr has none.
+func newResolver(addr string, timeout time.Duration) *net.Resolver {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+}
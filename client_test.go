@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	o := ClientOptions{
+		Timeout:       time.Minute,
+		DialTimeout:   time.Second,
+		TLSTimeout:    2 * time.Second,
+		HeaderTimeout: 3 * time.Second,
+	}
+	hc := NewHTTPClient(o)
+	assert.Equal(t, o.Timeout, hc.Timeout)
+	transport, ok := hc.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, o.TLSTimeout, transport.TLSHandshakeTimeout)
+	assert.Equal(t, o.HeaderTimeout, transport.ResponseHeaderTimeout)
+	assert.NotNil(t, transport.DialContext)
+	// the default transport is left intact
+	assert.NotEqual(t, o.HeaderTimeout, http.DefaultTransport.(*http.Transport).ResponseHeaderTimeout)
+}
+
+func TestNewResolver(t *testing.T) {
+	// a DNS server which only records the queries it receives
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+	queried := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 512)
+		if _, _, err := conn.ReadFrom(buf); err == nil {
+			queried <- struct{}{}
+		}
+	}()
+	r := newResolver(conn.LocalAddr().String(), time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = r.LookupHost(ctx, "foo.invalid")
+	assert.NotNil(t, err)
+	select {
+	case <-queried:
+	case <-time.After(time.Second):
+		t.Fatal("expect hosts looked up with given DNS server")
+	}
+}
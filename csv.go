@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// csvWalker walks CSV exports with a header row, where each of the other rows is a bookmark. It is shared by
+// walkers of CSV exports, which differ in how a row maps to a bookmark.
+type csvWalker struct {
+	chanWalker
+	r      io.Reader
+	gen    func(row csvRow) *Bookmark
+	header []string
+	rows   []*csvRecord
+}
+
+// csvRow looks up the column of a row by its name in header. Absent columns are empty.
+type csvRow func(name string) string
+
+type csvRecord struct {
+	fields []string
+	bmk    *Bookmark
+}
+
+func newCSVWalker(r io.Reader, log *zap.SugaredLogger, gen func(row csvRow) *Bookmark) *csvWalker {
+	w := &csvWalker{r: r, gen: gen}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *csvWalker) walk() {
+	defer close(w.wchan)
+	cr := csv.NewReader(w.r)
+	// tolerate rows missing trailing columns
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return
+	} else if err != nil {
+		w.send(&Result{nil, err})
+		return
+	}
+	w.header = header
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			w.send(&Result{nil, err})
+			return
+		}
+		row := func(name string) string {
+			if i, ok := index[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		bmk := w.gen(row)
+		w.rows = append(w.rows, &csvRecord{fields: fields, bmk: bmk})
+		w.log.Infow("created bookmark", "bookmark", bmk)
+		if !w.send(&Result{bmk, nil}) {
+			return
+		}
+	}
+}
+
+// WriteCleaned writes the header and the rows of alive bookmarks walked by w as CSV.
+func (w *csvWalker) WriteCleaned(out io.Writer) error {
+	cw := csv.NewWriter(out)
+	if w.header != nil {
+		if err := cw.Write(w.header); err != nil {
+			return err
+		}
+	}
+	for _, r := range w.rows {
+		if r.bmk.Status != Alive {
+			continue
+		}
+		if err := cw.Write(r.fields); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,238 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+const (
+	firefoxTypeBookmark = 1
+	firefoxTypeFolder   = 2
+	firefoxRootGUID     = "root________"
+	firefoxTagsGUID     = "tags________"
+)
+
+// titles of Firefox root folders, the same as what Firefox uses when exporting bookmarks to HTML
+var firefoxRootTitles = map[string]string{
+	"menu________": "Bookmarks Menu",
+	"toolbar_____": "Bookmarks Toolbar",
+	"unfiled_____": "Other Bookmarks",
+	"mobile______": "Mobile Bookmarks",
+}
+
+// FirefoxWalker walks the places.sqlite database of Firefox profile, in the same order as Firefox shows bookmarks.
+// Tags are walked as bookmark attributes instead of folders.
+//
+// The SQLite driver FirefoxWalker relies on requires cgo. Built without cgo, FirefoxWalker merely fails.
+type FirefoxWalker struct {
+	chanWalker
+	r io.Reader
+}
+
+// firefoxItem is a bookmark or folder in places.sqlite.
+type firefoxItem struct {
+	id      int64
+	typ     int
+	guid    string
+	title   string
+	addDate int64
+	placeID int64
+	url     string
+	keyword string
+}
+
+func NewFirefoxWalker(r io.Reader, log *zap.SugaredLogger) *FirefoxWalker {
+	w := &FirefoxWalker{r: r}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *FirefoxWalker) walk() {
+	defer close(w.wchan)
+	if err := w.walkDB(); err != nil {
+		w.send(&Result{nil, err})
+	}
+}
+
+// walkDB walks a copy of the database, so that the original one, which Firefox may still be using, is left
+// untouched.
+func (w *FirefoxWalker) walkDB() error {
+	dir, err := ioutil.TempDir("", "mwsh-places-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "places.sqlite")
+	if err := copyPlaces(path, w.r); err != nil {
+		return fmt.Errorf("failed to copy places database: %w", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	children, err := loadFirefoxItems(db)
+	if err != nil {
+		return err
+	}
+	tags, err := loadFirefoxTags(db)
+	if err != nil {
+		return err
+	}
+	var root int64
+	for _, it := range children[0] {
+		if it.guid == firefoxRootGUID {
+			root = it.id
+		}
+	}
+	_, err = w.walkFolder(root, nil, children, tags, map[int64]bool{})
+	return err
+}
+
+// walkFolder walks the items in folder id, whose folder path is given by path, depth first. It returns false if
+// walker is stopped.
+func (w *FirefoxWalker) walkFolder(id int64, path []string, children map[int64][]*firefoxItem,
+	tags map[int64][]string, visited map[int64]bool) (bool, error) {
+	// guard against cycles in corrupted database
+	if visited[id] {
+		return true, nil
+	}
+	visited[id] = true
+	for _, it := range children[id] {
+		if it.typ == firefoxTypeFolder {
+			if it.guid == firefoxTagsGUID {
+				// bookmarks under tag folders are merely tags of other bookmarks
+				continue
+			}
+			title := it.title
+			if t, ok := firefoxRootTitles[it.guid]; ok {
+				title = t
+			}
+			folder := append(append([]string(nil), path...), title)
+			if ok, err := w.walkFolder(it.id, folder, children, tags, visited); !ok || err != nil {
+				return ok, err
+			}
+			continue
+		}
+		bmk := &Bookmark{
+			URL:     it.url,
+			Title:   it.title,
+			AddDate: firefoxTime(it.addDate),
+			Folder:  path,
+			Tags:    tags[it.placeID],
+			Keyword: it.keyword,
+		}
+		w.log.Infow("created bookmark", "bookmark", bmk)
+		if !w.send(&Result{bmk, nil}) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// copyPlaces copies the database read from r to path. If r is a file, its write-ahead log and shared memory files
+// are copied as well, since changes not yet checkpointed into the database live in them.
+func copyPlaces(path string, r io.Reader) error {
+	if err := copyFile(path, r); err != nil {
+		return err
+	}
+	f, ok := r.(interface{ Name() string })
+	if !ok {
+		return nil
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		src, err := os.Open(f.Name() + suffix)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		err = copyFile(path+suffix, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile writes the content read from r to a new file at path.
+func copyFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// loadFirefoxItems loads all the bookmarks and folders, including the tag ones. The returned items are keyed by
+// the id of their parent folder, and ordered by their positions in it.
+func loadFirefoxItems(db *sql.DB) (map[int64][]*firefoxItem, error) {
+	rows, err := db.Query(`SELECT b.id, b.type, IFNULL(b.parent, 0), IFNULL(b.guid, ''), IFNULL(b.title, ''),
+	IFNULL(b.dateAdded, 0), IFNULL(p.id, 0), IFNULL(p.url, ''),
+	IFNULL((SELECT k.keyword FROM moz_keywords k WHERE k.place_id = p.id LIMIT 1), '')
+FROM moz_bookmarks b LEFT JOIN moz_places p ON b.fk = p.id
+WHERE b.type = ? OR b.type = ?
+ORDER BY b.parent, b.position`, firefoxTypeBookmark, firefoxTypeFolder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	children := map[int64][]*firefoxItem{}
+	for rows.Next() {
+		var parent int64
+		it := &firefoxItem{}
+		if err := rows.Scan(&it.id, &it.typ, &parent, &it.guid, &it.title, &it.addDate, &it.placeID, &it.url,
+			&it.keyword); err != nil {
+			return nil, err
+		}
+		children[parent] = append(children[parent], it)
+	}
+	return children, rows.Err()
+}
+
+// loadFirefoxTags returns tags of bookmarked places, keyed by place id.
+func loadFirefoxTags(db *sql.DB) (map[int64][]string, error) {
+	rows, err := db.Query(`SELECT b.fk, t.title FROM moz_bookmarks b
+	JOIN moz_bookmarks t ON b.parent = t.id
+	JOIN moz_bookmarks r ON t.parent = r.id
+WHERE r.guid = ? AND b.type = ? AND t.title IS NOT NULL
+ORDER BY t.title`, firefoxTagsGUID, firefoxTypeBookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := map[int64][]string{}
+	for rows.Next() {
+		var placeID int64
+		var tag string
+		if err := rows.Scan(&placeID, &tag); err != nil {
+			return nil, err
+		}
+		tags[placeID] = append(tags[placeID], tag)
+	}
+	return tags, rows.Err()
+}
+
+// firefoxTime converts timestamp in places.sqlite, which is microseconds since Unix epoch, to time.
+func firefoxTime(us int64) time.Time {
+	if us == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, us*int64(time.Microsecond))
+}
@@ -0,0 +1,30 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+import (
+	"errors"
+	"io"
+
+	"go.uber.org/zap"
+)
+
+var errFirefoxNoCgo = errors.New("walking Firefox places.sqlite requires mwsh to be built with cgo enabled")
+
+// FirefoxWalker would walk the places.sqlite database of Firefox profile, but the SQLite driver it relies on
+// requires cgo. Built without cgo, it merely fails.
+type FirefoxWalker struct {
+	chanWalker
+}
+
+func NewFirefoxWalker(_ io.Reader, log *zap.SugaredLogger) *FirefoxWalker {
+	w := &FirefoxWalker{}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *FirefoxWalker) walk() {
+	defer close(w.wchan)
+	w.send(&Result{nil, errFirefoxNoCgo})
+}
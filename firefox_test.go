@@ -0,0 +1,138 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// a trimmed-down places.sqlite, with only the tables and columns FirefoxWalker cares about
+const placesSchema = `
+CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR);
+CREATE TABLE moz_keywords (id INTEGER PRIMARY KEY AUTOINCREMENT, keyword TEXT UNIQUE, place_id INTEGER, post_data TEXT);
+CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER DEFAULT NULL, parent INTEGER,
+	position INTEGER, title LONGVARCHAR, keyword_id INTEGER, folder_type TEXT, dateAdded INTEGER, lastModified INTEGER,
+	guid TEXT);
+INSERT INTO moz_places (id, url, title) VALUES
+	(1, 'https://bar.io/', 'Bar'),
+	(2, 'https://qux.io/', 'Qux'),
+	(3, 'https://bee.io/', 'Bee');
+INSERT INTO moz_keywords (keyword, place_id) VALUES ('q', 2);
+INSERT INTO moz_bookmarks (id, type, fk, parent, position, title, dateAdded, guid) VALUES
+	(1, 2, NULL, 0, 0, '', 1515361177000000, 'root________'),
+	(2, 2, NULL, 1, 0, 'menu', 1515361177000000, 'menu________'),
+	(3, 2, NULL, 1, 1, 'toolbar', 1515361177000000, 'toolbar_____'),
+	(4, 2, NULL, 1, 2, 'tags', 1515361177000000, 'tags________'),
+	(5, 2, NULL, 1, 3, 'unfiled', 1515361177000000, 'unfiled_____'),
+	(6, 2, NULL, 3, 0, 'BarDir', 1515361177000000, 'bardir______'),
+	(7, 1, 1, 3, 1, 'Bar', 1515361177123456, 'bar_________'),
+	(8, 1, 2, 6, 0, 'Qux', 1515361177000000, 'qux_________'),
+	(9, 1, 3, 5, 0, NULL, 1515361173000000, 'bee_________'),
+	(10, 2, NULL, 4, 0, 'fun', 1515361177000000, 'tagfun______'),
+	(11, 1, 2, 10, 0, NULL, 1515361177000000, 'tagfunqux___'),
+	(12, 2, NULL, 4, 1, 'dev', 1515361177000000, 'tagdev______'),
+	(13, 1, 2, 12, 0, NULL, 1515361177000000, 'tagdevqux___');
+`
+
+func TestFirefoxWalker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mwsh-firefox-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "places.sqlite")
+	db, err := sql.Open("sqlite3", path)
+	require.Nil(t, err)
+	_, err = db.Exec(placesSchema)
+	require.Nil(t, err)
+	require.Nil(t, db.Close())
+	places, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+
+	w := NewFirefoxWalker(bytes.NewReader(places), genTstLogger())
+	bs := []*Bookmark{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		bs = append(bs, b)
+	}
+	assert.Equal(t, []*Bookmark{
+		{
+			URL:     "https://qux.io/",
+			Title:   "Qux",
+			AddDate: time.Unix(1515361177, 0),
+			Folder:  []string{"Bookmarks Toolbar", "BarDir"},
+			Tags:    []string{"dev", "fun"},
+			Keyword: "q",
+		},
+		{
+			URL:     "https://bar.io/",
+			Title:   "Bar",
+			AddDate: time.Unix(1515361177, 123456000),
+			Folder:  []string{"Bookmarks Toolbar"},
+		},
+		{
+			URL:     "https://bee.io/",
+			AddDate: time.Unix(1515361173, 0),
+			Folder:  []string{"Other Bookmarks"},
+		},
+	}, bs)
+}
+
+func TestFirefoxWalker_WriteAheadLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mwsh-firefox-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "places.sqlite")
+	// keep the database open as Firefox does, so that changes stay in the write-ahead log
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	require.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("PRAGMA wal_autocheckpoint = 0;" + placesSchema)
+	require.Nil(t, err)
+
+	for _, f := range []Format{FormatFirefox, FormatAuto} {
+		f := f
+		t.Run(string(f), func(t *testing.T) {
+			places, err := os.Open(path)
+			require.Nil(t, err)
+			defer places.Close()
+			w, err := NewWalker(f, places, genTstLogger(), WalkOptions{})
+			require.Nil(t, err)
+			n := 0
+			for {
+				_, err := w.Next(context.Background())
+				if err == io.EOF {
+					break
+				}
+				assert.Nil(t, err)
+				n++
+			}
+			assert.Equal(t, 3, n, "bookmarks in write-ahead log should have been walked")
+		})
+	}
+}
+
+func TestFirefoxWalker_NotDatabase(t *testing.T) {
+	w := NewFirefoxWalker(bytes.NewReader([]byte("<!DOCTYPE NETSCAPE-Bookmark-file-1>")), genTstLogger())
+	b, err := w.Next(context.Background())
+	assert.Nil(t, b)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, io.EOF, err)
+	_, err = w.Next(context.Background())
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Format is the format of input bookmark file.
+type Format string
+
+const (
+	FormatAuto      Format = "auto" // detect format from input
+	FormatNetscape  Format = "netscape"
+	FormatChrome    Format = "chrome"
+	FormatFirefox   Format = "firefox"
+	FormatSafari    Format = "safari"
+	FormatOPML      Format = "opml"
+	FormatLines     Format = "lines" // plain text with one URL per line
+	FormatMarkdown  Format = "markdown"
+	FormatPinboard  Format = "pinboard"   // Pinboard JSON export
+	FormatPocket    Format = "pocket"     // Pocket HTML export
+	FormatPocketCSV Format = "pocket-csv" // Pocket CSV export
+	FormatRaindrop  Format = "raindrop"   // Raindrop.io CSV export
+)
+
+// sniffLen is the max length of input prefix peeked to detect input format
+const sniffLen = 8 << 10
+
+var (
+	sqliteMagic = []byte("SQLite format 3\x00")
+	bplistMagic = []byte("bplist")
+	utf8BOM     = []byte("\xef\xbb\xbf")
+)
+
+var errUnknownFormat = errors.New("unable to detect bookmark format, specify it explicitly instead")
+
+// WalkOptions customizes walkers created by NewWalker.
+type WalkOptions struct {
+	// Strict aborts the walk on malformed bookmarks, for walkers supporting it
+	Strict bool
+}
+
+type walkerCtor func(io.Reader, *zap.SugaredLogger, WalkOptions) Walker
+
+// walkerCtors holds the constructor of Walker for each supported Format.
+var walkerCtors = map[Format]walkerCtor{
+	FormatNetscape: func(r io.Reader, log *zap.SugaredLogger, o WalkOptions) Walker {
+		w := NewNetscapeWalker(r, log)
+		w.Strict = o.Strict
+		return w
+	},
+	FormatChrome:  func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker { return NewChromeWalker(r, log) },
+	FormatFirefox: func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker { return NewFirefoxWalker(r, log) },
+	FormatSafari:  func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker { return NewSafariWalker(r, log) },
+	FormatLines:   func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker { return NewLineWalker(r, log) },
+	FormatOPML:    func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker { return NewOPMLWalker(r, log) },
+	FormatMarkdown: func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker {
+		return NewMarkdownWalker(r, log)
+	},
+	FormatPinboard: func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker {
+		return NewPinboardWalker(r, log)
+	},
+	FormatPocket: func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker { return NewPocketWalker(r, log) },
+	FormatPocketCSV: func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker {
+		return NewPocketCSVWalker(r, log)
+	},
+	FormatRaindrop: func(r io.Reader, log *zap.SugaredLogger, _ WalkOptions) Walker {
+		return NewRaindropWalker(r, log)
+	},
+}
+
+// NewWalker creates the Walker walking r in format f. If f is FormatAuto, the format is detected from r.
+func NewWalker(f Format, r io.Reader, log *zap.SugaredLogger, o WalkOptions) (Walker, error) {
+	if f == FormatAuto {
+		var err error
+		if f, r, err = DetectFormat(r); err != nil {
+			return nil, err
+		}
+		log.Debugw("detected bookmark format", "format", f)
+	}
+	ctor, ok := walkerCtors[f]
+	if !ok {
+		return nil, fmt.Errorf("unsupported bookmark format %q", f)
+	}
+	return ctor(r, log, o), nil
+}
+
+// formats returns names of all supported formats.
+func formats() string {
+	names := make([]string, 0, len(walkerCtors))
+	for f := range walkerCtors {
+		names = append(names, string(f))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// DetectFormat detects the bookmark format of r by peeking at its beginning. It returns a reader yielding the same
+// content as r, which should be used in place of r afterwards. The returned reader keeps the name of r, if r is a
+// file.
+func DetectFormat(r io.Reader) (Format, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	// error merely tells input is shorter than sniffLen, or there is error reading r, which walker will hit again
+	p, _ := br.Peek(sniffLen)
+	var out io.Reader = br
+	if n, ok := r.(interface{ Name() string }); ok {
+		out = &namedReader{Reader: br, name: n.Name()}
+	}
+	f := sniff(p)
+	if f == "" {
+		return "", out, errUnknownFormat
+	}
+	return f, out, nil
+}
+
+// namedReader reads the file of given name, via a reader other than the file itself. It lets walkers which need
+// more than the content of file, e.g. FirefoxWalker copying the write-ahead log along with the database, find the
+// file behind the reader.
+type namedReader struct {
+	io.Reader
+	name string
+}
+
+// Name returns the name of the file r reads, as *os.File does.
+func (r *namedReader) Name() string { return r.name }
+
+// sniff returns format of input which begins with p, or empty string if the format is unknown.
+func sniff(p []byte) Format {
+	switch {
+	case bytes.HasPrefix(p, sqliteMagic):
+		return FormatFirefox
+	case bytes.HasPrefix(p, bplistMagic):
+		return FormatSafari
+	}
+	p = bytes.TrimSpace(bytes.TrimPrefix(p, utf8BOM))
+	lower := bytes.ToLower(p)
+	switch {
+	case len(p) == 0:
+		// nothing to walk anyway
+		return FormatNetscape
+	case bytes.Contains(lower, []byte("<!doctype netscape-bookmark-file")):
+		return FormatNetscape
+	case p[0] == '{' && bytes.Contains(p, []byte(`"roots"`)):
+		return FormatChrome
+	case p[0] == '[' && bytes.Contains(p, []byte(`"href"`)):
+		return FormatPinboard
+	case p[0] == '<' && bytes.Contains(lower, []byte("<title>pocket export</title>")):
+		return FormatPocket
+	case p[0] == '<' && bytes.Contains(lower, []byte("<plist")):
+		return FormatSafari
+	case p[0] == '<' && bytes.Contains(lower, []byte("<opml")):
+		return FormatOPML
+	case p[0] == '<':
+		// exports of Netscape Bookmark File Format in the wild don't always come with the doctype
+		return FormatNetscape
+	case bytes.HasPrefix(lower, []byte("title,url,time_added")):
+		return FormatPocketCSV
+	case bytes.HasPrefix(lower, []byte("id,title,note,excerpt,url")):
+		return FormatRaindrop
+	case isURLList(p):
+		return FormatLines
+	case mdLinkRe.Match(p) || mdHeadingRe.Match(p):
+		return FormatMarkdown
+	}
+	return ""
+}
+
+// isURLList tells if p looks like a list of URLs, one per line, where blank lines and comments started with "#"
+// are allowed. Wash records output by mwsh count as such a list as well.
+func isURLList(p []byte) bool {
+	s := bufio.NewScanner(bytes.NewReader(p))
+	for s.Scan() {
+		if bmk := parseLine(s.Text()); bmk != nil {
+			u, err := url.Parse(bmk.URL)
+			return err == nil && u.Scheme != "" && u.Host != ""
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	binPlist, err := ioutil.ReadFile("testdata/safari/Bookmarks.binary.plist")
+	require.Nil(t, err)
+	xmlPlist, err := ioutil.ReadFile("testdata/safari/Bookmarks.xml.plist")
+	require.Nil(t, err)
+	tcs := []struct {
+		name string
+		in   string
+		exp  Format
+	}{
+		{"Netscape", "<!DOCTYPE NETSCAPE-Bookmark-file-1>\n<TITLE>Bookmarks</TITLE>", FormatNetscape},
+		{"NetscapeWithBOM", "\xef\xbb\xbf<!doctype netscape-bookmark-file-1>", FormatNetscape},
+		{"NetscapeWithoutDoctype", "<!-- DO NOT EDIT! -->\n<DL><p><DT><A HREF=\"https://foo.io\">Foo</A>", FormatNetscape},
+		{"Empty", "", FormatNetscape},
+		{"Chrome", "{\n   \"checksum\": \"d41d8cd98f00b204e9800998ecf8427e\",\n   \"roots\": {}}", FormatChrome},
+		{"Firefox", "SQLite format 3\x00\x10\x00\x01\x01", FormatFirefox},
+		{"SafariBinary", string(binPlist), FormatSafari},
+		{"SafariXML", string(xmlPlist), FormatSafari},
+		{"OPML", "<?xml version=\"1.0\"?>\n<opml version=\"2.0\"><head></head><body></body></opml>", FormatOPML},
+		{"URLList", "# links\n\nhttps://foo.io\thome of foo\nhttps://bar.io\n", FormatLines},
+		{"WashRecords", "alive\thttps://foo.io\t200\t-\tFooDir\t-\n", FormatLines},
+		{"Markdown", "# Reading List\n\n- [Foo](https://foo.io)\n", FormatMarkdown},
+		{"MarkdownLinkFirst", "- [Foo](https://foo.io)\n", FormatMarkdown},
+		{"Pinboard", "[{\"href\":\"https:\\/\\/foo.io\\/\",\"description\":\"Foo\"}]", FormatPinboard},
+		{"Pocket", "<!DOCTYPE html>\n<html>\n\t<head>\n\t\t<title>Pocket Export</title>", FormatPocket},
+		{"PocketCSV", "title,url,time_added,tags,status\nFoo,https://foo.io/,1609459200,,unread\n", FormatPocketCSV},
+		{"Raindrop", "id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite\n", FormatRaindrop},
+		{"UnknownJSON", "{\"foo\": 1}", ""},
+		{"UnknownText", "hello world", ""},
+	}
+	for _, c := range tcs {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			f, r, err := DetectFormat(strings.NewReader(c.in))
+			assert.Equal(t, c.exp, f)
+			if c.exp == "" {
+				assert.Equal(t, errUnknownFormat, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			// detection should not consume the input
+			b, err := ioutil.ReadAll(r)
+			assert.Nil(t, err)
+			assert.Equal(t, c.in, string(b))
+		})
+	}
+}
+
+func TestNewWalker_Auto(t *testing.T) {
+	f, err := os.Open("testdata/safari/Bookmarks.binary.plist")
+	require.Nil(t, err)
+	defer f.Close()
+	w, err := NewWalker(FormatAuto, f, genTstLogger(), WalkOptions{})
+	require.Nil(t, err)
+	assert.IsType(t, &SafariWalker{}, w)
+	b, err := w.Next(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "https://bar.io/", b.URL)
+	w.Stop()
+
+	_, err = NewWalker(FormatAuto, bytes.NewReader([]byte("hello world")), genTstLogger(), WalkOptions{})
+	assert.Equal(t, errUnknownFormat, err)
+	_, err = NewWalker(Format("wat"), bytes.NewReader(nil), genTstLogger(), WalkOptions{})
+	assert.NotNil(t, err)
+}
@@ -5,7 +5,9 @@ go 1.14
 require (
 	github.com/avast/retry-go v2.6.0+incompatible
 	github.com/gogo/protobuf v1.3.1
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/stretchr/testify v1.6.1
 	go.uber.org/zap v1.15.0
 	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
+	howett.net/plist v1.0.0
 )
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	urlpkg "net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostGates keeps pings of each host within the concurrency limit, and apart from each other by the politeness
+// delay. Hosts are gated independently, so that pings waiting for a busy host don't hold back the other hosts.
+type hostGates struct {
+	limit int           // max concurrent pings per host, or 0 for unlimited
+	delay time.Duration // min delay between the starts of pings to a host
+	mu    sync.Mutex
+	gates map[string]*hostGate
+}
+
+// hostGate gates pings of a host.
+type hostGate struct {
+	sem  chan struct{} // nil if concurrency is unlimited
+	mu   sync.Mutex
+	next time.Time // earliest time the next ping may start
+}
+
+func newHostGates(limit int, delay time.Duration) *hostGates {
+	return &hostGates{limit: limit, delay: delay, gates: map[string]*hostGate{}}
+}
+
+// acquire waits till url can be pinged per the limits of its host. It returns the func to release the acquired
+// gate once the ping is done, or false if done is closed before then.
+func (h *hostGates) acquire(url string, done <-chan struct{}) (func(), bool) {
+	g := h.gate(hostOf(url))
+	if g == nil {
+		return func() {}, true
+	}
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-done:
+			return nil, false
+		}
+	}
+	release := func() {
+		if g.sem != nil {
+			<-g.sem
+		}
+	}
+	if h.delay <= 0 {
+		return release, true
+	}
+	// reserve the next start time of host, so that concurrent pings of host are spaced by delay as well
+	g.mu.Lock()
+	now := time.Now()
+	start := g.next
+	if start.Before(now) {
+		start = now
+	}
+	g.next = start.Add(h.delay)
+	g.mu.Unlock()
+	if wait := start.Sub(now); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-done:
+			release()
+			return nil, false
+		}
+	}
+	return release, true
+}
+
+// gate returns the gate of host, or nil if host needs no gating.
+func (h *hostGates) gate(host string) *hostGate {
+	if host == "" || (h.limit <= 0 && h.delay <= 0) {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	g, ok := h.gates[host]
+	if !ok {
+		g = &hostGate{}
+		if h.limit > 0 {
+			g.sem = make(chan struct{}, h.limit)
+		}
+		h.gates[host] = g
+	}
+	return g
+}
+
+// hostOf returns the lowercased host of url, or empty string if url has none.
+func hostOf(url string) string {
+	u, err := urlpkg.Parse(strings.TrimSpace(url))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// pingSlot is what a ping of url holds while making network requests, i.e. the gate of its host and a share of the
+// concurrency quota. It rides along ping via context, so that pinger acquires it only if the ping goes over the
+// network, and releases it while waiting, e.g. for its host to be backed off, instead of holding back pings of the
+// other hosts. It is used by the goroutine of ping only.
+type pingSlot struct {
+	url         string
+	hosts       *hostGates
+	cquota      chan struct{}
+	start       context.Context // bounds the first acquisition, i.e. the start of ping, if not nil
+	releaseHost func()
+	held        bool
+	started     bool
+}
+
+// errNotStarted tells the ping is abandoned before it starts, as the start ctx of its slot is done.
+var errNotStarted = errors.New("ping is not started")
+
+type pingSlotKey struct{}
+
+// withPingSlot returns a copy of ctx carrying s.
+func withPingSlot(ctx context.Context, s *pingSlot) context.Context {
+	return context.WithValue(ctx, pingSlotKey{}, s)
+}
+
+// pingSlotOf returns the pingSlot carried by ctx, or nil if there is none.
+func pingSlotOf(ctx context.Context) *pingSlot {
+	s, _ := ctx.Value(pingSlotKey{}).(*pingSlot)
+	return s
+}
+
+// acquire waits for the host gate then the concurrency quota, so that a busy host doesn't hold back the others.
+// It returns false if ctx, or the start ctx of s for the first acquisition, is done before then.
+func (s *pingSlot) acquire(ctx context.Context) bool {
+	if !s.started && s.start != nil {
+		ctx = s.start
+	}
+	release, ok := s.hosts.acquire(s.url, ctx.Done())
+	if !ok {
+		return false
+	}
+	select {
+	case s.cquota <- struct{}{}:
+	case <-ctx.Done():
+		release()
+		return false
+	}
+	if ctx.Err() != nil {
+		// ctx may be done while both are acquired at the same time
+		<-s.cquota
+		release()
+		return false
+	}
+	s.releaseHost, s.held, s.started = release, true, true
+	return true
+}
+
+// release gives back what s holds, if any.
+func (s *pingSlot) release() {
+	if !s.held {
+		return
+	}
+	<-s.cquota
+	s.releaseHost()
+	s.held = false
+}
+
+// hostBackoffs tracks hosts that asked to be backed off, e.g. by responding 429 Too Many Requests, so that all
+// pings of a host hold off till its backoff is over.
+type hostBackoffs struct {
+	mu       sync.Mutex
+	backoffs map[string]*hostBackoff
+}
+
+type hostBackoff struct {
+	until time.Time     // time till which pings of host hold off
+	step  time.Duration // last backoff of host which doesn't tell how long to back off, doubled on each throttle
+}
+
+func newHostBackoffs() *hostBackoffs {
+	return &hostBackoffs{backoffs: map[string]*hostBackoff{}}
+}
+
+// wait blocks till backoff of host, if any, is over, idling the ping slot carried by ctx meanwhile. It returns
+// ctx.Err() if ctx is done before then.
+func (h *hostBackoffs) wait(ctx context.Context, host string) error {
+	h.mu.Lock()
+	var until time.Time
+	if b, ok := h.backoffs[host]; ok {
+		until = b.until
+	}
+	h.mu.Unlock()
+	return idle(ctx, time.Until(until))
+}
+
+// throttle backs off host for d, capped by ceiling unless ceiling is 0. If d is not positive, i.e. host doesn't
+// tell how long to back off, host is backed off exponentially from base instead. Backoff of host is never
+// shortened. It returns the backoff applied.
+func (h *hostBackoffs) throttle(host string, d, base, ceiling time.Duration) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.backoffs[host]
+	if !ok {
+		b = &hostBackoff{}
+		h.backoffs[host] = b
+	}
+	if d <= 0 {
+		if b.step *= 2; b.step <= 0 {
+			b.step = base
+		}
+		if ceiling > 0 && b.step > ceiling {
+			b.step = ceiling
+		}
+		d = b.step
+	}
+	if ceiling > 0 && d > ceiling {
+		d = ceiling
+	}
+	if until := time.Now().Add(d); until.After(b.until) {
+		b.until = until
+	}
+	return d
+}
+
+// reset clears the exponential backoff of host once it responds normally again.
+func (h *hostBackoffs) reset(host string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if b, ok := h.backoffs[host]; ok {
+		b.step = 0
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostGates_Limit(t *testing.T) {
+	h, done := newHostGates(1, 0), make(chan struct{})
+	release, ok := h.acquire("https://foo.io/a", done)
+	require.True(t, ok)
+	acquired := make(chan struct{})
+	go func() {
+		defer close(acquired)
+		r, ok := h.acquire("https://FOO.io/b", done)
+		assert.True(t, ok)
+		r()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("host limit should have held back the second ping")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// other hosts are not held back
+	r, ok := h.acquire("https://bar.io/", done)
+	assert.True(t, ok)
+	r()
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("releasing the host should have let the second ping through")
+	}
+}
+
+func TestHostGates_Delay(t *testing.T) {
+	delay := 50 * time.Millisecond
+	h, done := newHostGates(0, delay), make(chan struct{})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		r, ok := h.acquire(fmt.Sprintf("https://foo.io/%d", i), done)
+		require.True(t, ok)
+		r()
+	}
+	assert.True(t, time.Since(start) >= 2*delay, "pings to the same host should have been delayed")
+	start = time.Now()
+	r, ok := h.acquire("https://bar.io/", done)
+	require.True(t, ok)
+	r()
+	assert.True(t, time.Since(start) < delay, "pings to other hosts should not have been delayed")
+}
+
+func TestHostGates_Stop(t *testing.T) {
+	for name, h := range map[string]*hostGates{"Limit": newHostGates(1, 0), "Delay": newHostGates(0, time.Hour)} {
+		h := h
+		t.Run(name, func(t *testing.T) {
+			done := make(chan struct{})
+			_, ok := h.acquire("https://foo.io/", done)
+			require.True(t, ok)
+			close(done)
+			_, ok = h.acquire("https://foo.io/", done)
+			assert.False(t, ok)
+		})
+	}
+}
+
+// concurrencyDoer records the max number of concurrent requests.
+type concurrencyDoer struct {
+	mu       sync.Mutex
+	cur, max int
+}
+
+func (d *concurrencyDoer) Do(req *http.Request) (*http.Response, error) {
+	d.mu.Lock()
+	if d.cur++; d.cur > d.max {
+		d.max = d.cur
+	}
+	d.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	d.mu.Lock()
+	d.cur--
+	d.mu.Unlock()
+	return genResp(http.StatusOK), nil
+}
+
+func TestWasher_HostLimit(t *testing.T) {
+	wmock := &walkerMock{}
+	for i := 0; i < 8; i++ {
+		wmock.On("Next").Return(&Bookmark{URL: fmt.Sprintf("https://foo.io/%d", i)}, nil).Once()
+	}
+	wmock.On("Next").Return((*Bookmark)(nil), io.EOF).Once()
+	d := &concurrencyDoer{}
+	washer := NewWasher(wmock, NewHTTPinger(d, genTstLogger()), genTstLogger(), 8)
+	washer.HostLimit = 2
+	defer washer.Stop()
+	n := 0
+	for {
+		_, err := washer.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		n++
+	}
+	assert.Equal(t, 8, n)
+	assert.True(t, d.max <= 2, "expect at most 2 concurrent pings to the same host but got %d", d.max)
+}
+
+func TestStartWashTillDone_HostDelayOffline(t *testing.T) {
+	sb := &strings.Builder{}
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(sb, "https://foo.io/%d\n", i)
+	}
+	out := &bytes.Buffer{}
+	start := time.Now()
+	StartWashTillDone(context.Background(), strings.NewReader(sb.String()), out, &doerMock{}, 2, genTstLogger(),
+		WithSkipNetwork(nil), WithHostLimits(1, time.Second))
+	elapsed := time.Since(start)
+	assert.Equal(t, 20, strings.Count(out.String(), "\n"))
+	assert.True(t, elapsed < time.Second, "URLs decided without network request should not have been held back "+
+		"by host limits, but took %s", elapsed)
+}
+
+func TestHostBackoffs(t *testing.T) {
+	base := 10 * time.Millisecond
+	h := newHostBackoffs()
+	// exponential backoff when host doesn't tell how long to back off, capped by ceiling
+	for _, exp := range []time.Duration{base, 2 * base, 4 * base, 5 * base, 5 * base} {
+		assert.Equal(t, exp, h.throttle("foo.io", 0, base, 5*base))
+	}
+	h.reset("foo.io")
+	assert.Equal(t, base, h.throttle("foo.io", 0, base, 0))
+	// backoff told by host is capped by ceiling too
+	assert.Equal(t, time.Hour, h.throttle("bar.io", time.Hour, base, 0))
+	assert.Equal(t, time.Second, h.throttle("baz.io", time.Hour, base, time.Second))
+	// backoff of host is never shortened
+	h.throttle("baz.io", time.Millisecond, base, 0)
+	assert.True(t, time.Until(h.backoffs["baz.io"].until) > 500*time.Millisecond)
+	start := time.Now()
+	assert.Nil(t, h.wait(context.Background(), "qux.io"))
+	assert.True(t, time.Since(start) < base, "hosts not backed off should not wait")
+}
+
+func TestWasher_BackoffNotHoldingOtherHosts(t *testing.T) {
+	wmock := &walkerMock{}
+	for i := 0; i < 4; i++ {
+		wmock.On("Next").Return(&Bookmark{URL: fmt.Sprintf("https://busy.io/%d", i)}, nil).Once()
+	}
+	wmock.On("Next").Return(&Bookmark{URL: "https://idle.io/"}, nil).Once()
+	wmock.On("Next").Return((*Bookmark)(nil), io.EOF).Once()
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "idle.io" {
+			return genResp(http.StatusOK), nil
+		}
+		resp := genResp(http.StatusTooManyRequests)
+		resp.Header = http.Header{"Retry-After": []string{"2"}}
+		return resp, nil
+	})
+	washer := NewWasher(wmock, NewHTTPinger(doer, genTstLogger()), genTstLogger(), 2)
+	defer washer.Stop()
+	start := time.Now()
+	for {
+		b, err := washer.Next(context.Background())
+		require.NotEqual(t, io.EOF, err)
+		if b.URL == "https://idle.io/" {
+			break
+		}
+	}
+	elapsed := time.Since(start)
+	assert.True(t, elapsed < time.Second, "pings of a backed off host should not have held back the others, "+
+		"but took %s", elapsed)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// LineWalker walks plain text holding one URL per line, e.g. link dumps and whitelist files. Each line is either
+// "<url>" optionally followed by a tab and the title, or a wash record previously output by mwsh. Blank lines and
+// comments started with "#" are skipped.
+type LineWalker struct {
+	chanWalker
+	r io.Reader
+}
+
+func NewLineWalker(r io.Reader, log *zap.SugaredLogger) *LineWalker {
+	w := &LineWalker{r: r}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *LineWalker) walk() {
+	defer close(w.wchan)
+	s := bufio.NewScanner(w.r)
+	for s.Scan() {
+		bmk := parseLine(s.Text())
+		if bmk == nil {
+			continue
+		}
+		w.log.Infow("created bookmark", "bookmark", bmk)
+		if !w.send(&Result{bmk, nil}) {
+			return
+		}
+	}
+	if err := s.Err(); err != nil {
+		w.send(&Result{nil, err})
+	}
+}
+
+// parseLine parses a line of URL list into bookmark, or returns nil if the line is blank or a comment. For a wash
+// record in form of "<status> <url> <respCode> <error> <folder> <description>", the status, folder and description
+// are restored as well.
+func parseLine(line string) *Bookmark {
+	cols := splitLine(line)
+	if cols == nil {
+		return nil
+	}
+	status, err := ParsePingStatus(cols[0])
+	if err != nil || len(cols) < 2 {
+		bmk := &Bookmark{URL: cols[0]}
+		if len(cols) > 1 {
+			bmk.Title = collapseSpace(strings.Join(cols[1:], " "))
+		}
+		return bmk
+	}
+	// column of wash record, where "-" stands for unavailable
+	col := func(i int) string {
+		if i < len(cols) && cols[i] != "-" {
+			return cols[i]
+		}
+		return ""
+	}
+	bmk := &Bookmark{URL: cols[1], Status: status, Description: col(5)}
+	if f := col(4); f != "" {
+		bmk.Folder = strings.Split(f, "/")
+	}
+	return bmk
+}
+
+// splitLine splits a line of URL list or wash records into tab-separated columns, or returns nil if the line is
+// blank or a comment.
+func splitLine(line string) []string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	return strings.Split(line, "\t")
+}
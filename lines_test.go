@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineWalker(t *testing.T) {
+	in := `# links worth reading
+
+https://foo.io/
+  https://bar.io/	Bar   of the  year	2021
+alive	https://qux.io/	200	-	FooDir/QuxDir	about qux
+dead	https://dead.io/	404	http_4xx	-	-
+unknown	https://bee.io/
+`
+	w := NewLineWalker(strings.NewReader(in), genTstLogger())
+	bs := []*Bookmark{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		bs = append(bs, b)
+	}
+	assert.Equal(t, []*Bookmark{
+		{URL: "https://foo.io/"},
+		{URL: "https://bar.io/", Title: "Bar of the year 2021"},
+		{URL: "https://qux.io/", Status: Alive, Folder: []string{"FooDir", "QuxDir"}, Description: "about qux"},
+		{URL: "https://dead.io/", Status: Dead},
+		{URL: "https://bee.io/", Status: Unknown},
+	}, bs)
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -13,41 +17,239 @@ import (
 func main() {
 	// always output wash result to stdout, all others to stderr. Provide flag to adjust verbosity level.
 	cqFlg := flag.Int("c", 16, "set networking concurreny limit")
+	hcFlg := flag.Int("host-concurrency", 4, "limit concurrent requests to the same host. 0 means no limit")
+	hdFlg := flag.Duration("host-delay", 0, "min delay between requests to the same host, e.g. 500ms")
+	gFlg := flag.Duration("grace", DefaultGracePeriod, "on interrupt, max time to wait for requests in flight "+
+		"before writing results so far. Interrupt again to exit right away")
+	mbFlg := flag.Duration("max-backoff", DefaultMaxBackoff, "max time to back off a host responding 429 or 503, "+
+		"and for a URL to wait out such backoffs before giving up. 0 means no limit")
+	toFlg := flag.Duration("timeout", DefaultClientOptions.Timeout, "timeout of a request as a whole, including "+
+		"redirects. 0 means no timeout")
+	dtoFlg := flag.Duration("dial-timeout", DefaultClientOptions.DialTimeout, "timeout of connecting to a host, "+
+		"including DNS lookup. 0 means no timeout")
+	ttoFlg := flag.Duration("tls-timeout", DefaultClientOptions.TLSTimeout, "timeout of TLS handshake. 0 means no "+
+		"timeout")
+	htoFlg := flag.Duration("header-timeout", DefaultClientOptions.HeaderTimeout, "timeout of waiting for response "+
+		"header once request is sent. 0 means no timeout")
+	raFlg := flag.Uint("retry-attempts", DefaultAttempts, "max requests per HTTP method to check a URL")
+	rdFlg := flag.Duration("retry-delay", DefaultRetryDelay, "delay before the first retry of a request, doubled "+
+		"on each of the following retries")
+	dnsFlg := flag.String("dns", "", "look up hosts with DNS server at given address, e.g. 1.1.1.1:53, instead of "+
+		"system resolver")
 	vFlg := flag.Bool("v", false, "enable verbose mode")
+	strictFlg := flag.Bool("strict", false, "abort on malformed bookmark instead of tolerating it with warnings")
+	var format string
+	fUsage := "format of input bookmark file, one of: " + formats() + ". Detect from input if " + string(FormatAuto)
+	flag.StringVar(&format, "f", string(FormatAuto), fUsage)
+	flag.StringVar(&format, "format", string(FormatAuto), fUsage)
+	oFlg := flag.String("o", "", "write alive bookmarks to given file, in the same structure as input. With "+
+		"multiple inputs, write them to files of the same names as inputs under given directory")
+	aFlg := flag.String("a", "", "additionally write results of alive URLs to given file")
+	dFlg := flag.String("d", "", "additionally write results of dead URLs to given file")
+	uFlg := flag.String("u", "", "additionally write results of URLs in unknown status to given file")
+	wFlg := flag.String("w", "", "keep URLs listed in given whitelist file(one URL per line) without checking them")
+	sFlg := flag.Bool("s", false, "skip network requests; decide URL statuses by whitelist and previous results only")
+	pFlg := flag.String("p", "", "look up URL statuses from given result file of a previous run. Only takes effect with -s")
+	dupFlg := flag.String("report-duplicates", "", "write bookmarks sharing the same canonical URL to given file")
+	stFlg := flag.Bool("strip-tracking", false, "consider URLs differing only in utm_* query parameters the same")
+	sfFlg := flag.Bool("strip-fragment", false, "consider URLs differing only in fragment the same")
+	rFlg := flag.String("recheck", "", "take result of a previous run as input, and check again only URLs in given "+
+		"comma-separated statuses, e.g. unknown,dead")
 	flag.Usage = usage
 	flag.Parse()
 	if *cqFlg <= 0 {
 		fmt.Println("networking concurrency limit must be positive")
 		os.Exit(1)
 	}
+	if *hcFlg < 0 || *hdFlg < 0 || *mbFlg < 0 {
+		fmt.Println("per-host concurrency limit, delay and max backoff must not be negative")
+		os.Exit(1)
+	}
+	if *toFlg < 0 || *dtoFlg < 0 || *ttoFlg < 0 || *htoFlg < 0 || *rdFlg < 0 || *gFlg < 0 {
+		fmt.Println("timeouts, retry delay and grace period must not be negative")
+		os.Exit(1)
+	}
+	if *raFlg == 0 {
+		fmt.Println("retry attempts must be positive")
+		os.Exit(1)
+	}
+	if _, ok := walkerCtors[Format(format)]; !ok && Format(format) != FormatAuto {
+		fmt.Printf("unsupported bookmark format %s\n", format)
+		os.Exit(1)
+	}
 	log := setUpLog(*vFlg)
 	defer log.Sync()
-	// default read from stdin. if input file is present, read from input file.
-	in := os.Stdin
+	// default read from stdin. if input files are present, read from input files.
+	srcs := []Source{{R: os.Stdin}}
 	if flag.NArg() > 0 {
-		infile := flag.Args()[0]
-		var err error
-		in, err = os.Open(infile)
+		srcs = srcs[:0]
+		for _, infile := range flag.Args() {
+			in, err := os.Open(infile)
+			if err != nil {
+				fmt.Printf("error opening input bookmark file %s: %s\n", infile, err)
+				os.Exit(1)
+			}
+			defer in.Close()
+			src := Source{R: in}
+			if flag.NArg() > 1 {
+				src.Name = infile
+			}
+			srcs = append(srcs, src)
+		}
+	}
+	canon := CanonOptions{StripTracking: *stFlg, StripFragment: *sfFlg}
+	opts := []Option{
+		WithFormat(Format(format)),
+		WithCanonOptions(canon),
+		WithHostLimits(*hcFlg, *hdFlg),
+		WithMaxBackoff(*mbFlg),
+		WithRetries(*raFlg, *rdFlg),
+		WithGracePeriod(*gFlg),
+		WithSummary(os.Stderr),
+	}
+	if *strictFlg {
+		opts = append(opts, WithStrict())
+	}
+	if *oFlg != "" {
+		outs, err := openCleanedOutputs(*oFlg, flag.Args())
+		if err != nil {
+			fmt.Printf("error opening output bookmark file %s: %s\n", *oFlg, err)
+			os.Exit(1)
+		}
+		for i, out := range outs {
+			defer out.Close()
+			srcs[i].Cleaned = out
+		}
+	}
+	for status, spec := range map[PingStatus]string{Alive: *aFlg, Dead: *dFlg, Unknown: *uFlg} {
+		if spec == "" {
+			continue
+		}
+		out, err := openSink(spec)
+		if err != nil {
+			fmt.Printf("error opening output of %s URLs %s: %s\n", status, spec, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+		opts = append(opts, WithStatusOutput(status, out))
+	}
+	if *dupFlg != "" {
+		out, err := openSink(*dupFlg)
+		if err != nil {
+			fmt.Printf("error opening output of duplicate bookmarks %s: %s\n", *dupFlg, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+		opts = append(opts, WithDuplicateReport(out))
+	}
+	if *wFlg != "" {
+		wl, err := loadWhitelist(*wFlg, canon)
+		if err != nil {
+			fmt.Printf("error loading whitelist file %s: %s\n", *wFlg, err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithWhitelist(wl))
+	}
+	if *rFlg != "" {
+		statuses, err := parseStatuses(*rFlg)
 		if err != nil {
-			fmt.Printf("error opening input bookmark file %s: %s\n", infile, err)
+			fmt.Printf("error parsing statuses to recheck %s: %s\n", *rFlg, err)
 			os.Exit(1)
 		}
+		opts = append(opts, WithRecheck(statuses...))
+	}
+	if *sFlg {
+		var prior map[string]PingStatus
+		if *pFlg != "" {
+			var err error
+			if prior, err = loadResults(*pFlg, canon); err != nil {
+				fmt.Printf("error loading previous results %s: %s\n", *pFlg, err)
+				os.Exit(1)
+			}
+		}
+		opts = append(opts, WithSkipNetwork(prior))
 	}
-	hc := setupHttpClient( /* TODO: customize timeouts and DNS based on user input */ )
-	StartWashTillDone(in, os.Stdout, hc, *cqFlg, log)
+	hc := NewHTTPClient(ClientOptions{
+		Timeout:       *toFlg,
+		DialTimeout:   *dtoFlg,
+		TLSTimeout:    *ttoFlg,
+		HeaderTimeout: *htoFlg,
+		Resolver:      *dnsFlg,
+	})
+	StartWashSourcesTillDone(context.Background(), srcs, os.Stdout, hc, *cqFlg, log, opts...)
 }
 
 // customized cli usage
 func usage() {
-	fmt.Fprint(flag.CommandLine.Output(), `Usage:  mwsh [Options] file
+	fmt.Fprint(flag.CommandLine.Output(), `Usage:  mwsh [Options] [file...]
 
 Check if your browser bookmarks are still alive.
 
 Options:
 `)
 	flag.PrintDefaults()
+	fmt.Fprint(flag.CommandLine.Output(), `
+Output destinations accept "-" for stdout and "&N" for file descriptor N, e.g. "&3".
+`)
+}
+
+// openSink opens the output destination specified by spec, which is either "-" for stdout, "&N" for an already
+// open file descriptor N, or a file path.
+func openSink(spec string) (io.WriteCloser, error) {
+	if spec == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	if strings.HasPrefix(spec, "&") {
+		fd, err := strconv.ParseUint(spec[1:], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file descriptor: %w", err)
+		}
+		f := os.NewFile(uintptr(fd), "fd"+spec[1:])
+		if _, err := f.Stat(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return os.Create(spec)
+}
+
+// openCleanedOutputs opens the destination of cleaned bookmarks for each of infiles. Given a single input, spec is
+// the destination as accepted by openSink, otherwise it is a directory holding the outputs named after inputs.
+func openCleanedOutputs(spec string, infiles []string) ([]io.WriteCloser, error) {
+	if len(infiles) <= 1 {
+		out, err := openSink(spec)
+		if err != nil {
+			return nil, err
+		}
+		return []io.WriteCloser{out}, nil
+	}
+	if err := os.MkdirAll(spec, 0755); err != nil {
+		return nil, err
+	}
+	outs := make([]io.WriteCloser, 0, len(infiles))
+	names := map[string]bool{}
+	for _, infile := range infiles {
+		name := filepath.Base(infile)
+		if names[name] {
+			return nil, fmt.Errorf("multiple inputs are named %s", name)
+		}
+		names[name] = true
+		out, err := os.Create(filepath.Join(spec, name))
+		if err != nil {
+			return nil, err
+		}
+		outs = append(outs, out)
+	}
+	return outs, nil
+}
+
+// nopWriteCloser keeps the underlying writer open on Close.
+type nopWriteCloser struct {
+	io.Writer
 }
 
+func (nopWriteCloser) Close() error { return nil }
+
 func setUpLog(verbose bool) *zap.SugaredLogger {
 	opts := []zap.Option{zap.IncreaseLevel(zapcore.WarnLevel)}
 	if verbose {
@@ -60,6 +262,33 @@ func setUpLog(verbose bool) *zap.SugaredLogger {
 	return log.Sugar()
 }
 
-func setupHttpClient() *http.Client {
-	return &http.Client{}
+func loadWhitelist(path string, o CanonOptions) (Whitelist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadWhitelist(f, o)
+}
+
+// parseStatuses parses comma-separated ping statuses.
+func parseStatuses(s string) ([]PingStatus, error) {
+	var statuses []PingStatus
+	for _, v := range strings.Split(s, ",") {
+		status, err := ParsePingStatus(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func loadResults(path string, o CanonOptions) (map[string]PingStatus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadResults(f, o)
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	mdHeadingRe = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	mdFenceRe   = regexp.MustCompile("^ {0,3}(```|~~~)")
+	mdCodeRe    = regexp.MustCompile("`[^`]*`")
+	// inline link [title](url "optional title"), where url may hold a pair of parentheses, e.g. Wikipedia URLs.
+	// Image ![alt](src) is matched as well, so that it can be told apart.
+	mdLinkRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*<?((?:[^\s<>()]|\([^\s()]*\))+)>?(?:\s+(?:"[^"]*"|'[^']*'))?\s*\)`)
+	// autolink <url>
+	mdAutolinkRe = regexp.MustCompile(`<(\w+://[^\s<>]+)>`)
+	// link reference definition [label]: url "optional title"
+	mdRefDefRe = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:\s*<?([^\s<>]+)>?`)
+)
+
+// MarkdownWalker walks links in Markdown documents, e.g. reading lists and READMEs. Headings make up folders, so
+// a link belongs to the folder of its nearest heading and the enclosing headings of higher levels. Links in code
+// blocks, images and relative links are skipped.
+type MarkdownWalker struct {
+	chanWalker
+	r io.Reader
+}
+
+func NewMarkdownWalker(r io.Reader, log *zap.SugaredLogger) *MarkdownWalker {
+	w := &MarkdownWalker{r: r}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *MarkdownWalker) walk() {
+	defer close(w.wchan)
+	// headings holds the title of the current heading of each level
+	var headings [6]string
+	var fence string
+	s := bufio.NewScanner(w.r)
+	for s.Scan() {
+		line := s.Text()
+		if m := mdFenceRe.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if fence == m[1] {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+		line = mdCodeRe.ReplaceAllString(line, "")
+		if m := mdHeadingRe.FindStringSubmatch(line); m != nil {
+			level := len(m[1])
+			headings[level-1] = collapseSpace(mdLinkRe.ReplaceAllString(m[2], "$2"))
+			for i := level; i < len(headings); i++ {
+				headings[i] = ""
+			}
+		}
+		for _, bmk := range mdLinks(line) {
+			bmk.Folder = mdFolder(headings[:])
+			w.log.Infow("created bookmark", "bookmark", bmk)
+			if !w.send(&Result{bmk, nil}) {
+				return
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		w.send(&Result{nil, err})
+	}
+}
+
+// mdLinks returns bookmarks of absolute links in line, in the order they appear.
+func mdLinks(line string) []*Bookmark {
+	var bmks []*Bookmark
+	add := func(url, title string) {
+		if strings.Contains(url, "://") {
+			bmks = append(bmks, &Bookmark{URL: url, Title: collapseSpace(title)})
+		}
+	}
+	if m := mdRefDefRe.FindStringSubmatch(line); m != nil {
+		add(m[2], m[1])
+		return bmks
+	}
+	type match struct {
+		at         int
+		url, title string
+	}
+	var ms []match
+	for _, m := range mdLinkRe.FindAllStringSubmatchIndex(line, -1) {
+		if m[3] > m[2] {
+			// image
+			continue
+		}
+		ms = append(ms, match{m[0], line[m[6]:m[7]], line[m[4]:m[5]]})
+	}
+	for _, m := range mdAutolinkRe.FindAllStringSubmatchIndex(line, -1) {
+		ms = append(ms, match{m[0], line[m[2]:m[3]], ""})
+	}
+	// the two kinds of links can't overlap, so sorting by position restores their order in line
+	sort.Slice(ms, func(i, j int) bool { return ms[i].at < ms[j].at })
+	for _, m := range ms {
+		add(m.url, m.title)
+	}
+	return bmks
+}
+
+// mdFolder returns the folder path made up of non-empty headings.
+func mdFolder(headings []string) []string {
+	var path []string
+	for _, h := range headings {
+		if h != "" {
+			path = append(path, h)
+		}
+	}
+	return path
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownWalker(t *testing.T) {
+	in := "Intro with [a link](https://intro.io) and <https://auto.io/x>.\n" +
+		"\n" +
+		"# Reading List #\n" +
+		"\n" +
+		"- [Effective   Go](https://go.dev/doc/effective_go \"the doc\")\n" +
+		"- ![logo](https://img.io/logo.png) [Relative](./docs/README.md) [Mail](mailto:foo@bar.io)\n" +
+		"\n" +
+		"## [Papers](https://papers.io)\n" +
+		"\n" +
+		"* [Go (language)](https://en.wikipedia.org/wiki/Go_(programming_language)), <https://go.dev>\n" +
+		"\n" +
+		"```md\n" +
+		"[in code](https://code.io)\n" +
+		"```\n" +
+		"Inline `[code](https://code.io)` span\n" +
+		"\n" +
+		"# Refs\n" +
+		"\n" +
+		"[rfc]: https://www.rfc-editor.org/rfc/rfc3986 \"URI\"\n"
+	w := NewMarkdownWalker(strings.NewReader(in), genTstLogger())
+	bs := []*Bookmark{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		bs = append(bs, b)
+	}
+	assert.Equal(t, []*Bookmark{
+		{URL: "https://intro.io", Title: "a link"},
+		{URL: "https://auto.io/x"},
+		{URL: "https://go.dev/doc/effective_go", Title: "Effective Go", Folder: []string{"Reading List"}},
+		{URL: "https://papers.io", Title: "Papers", Folder: []string{"Reading List", "Papers"}},
+		{
+			URL:    "https://en.wikipedia.org/wiki/Go_(programming_language)",
+			Title:  "Go (language)",
+			Folder: []string{"Reading List", "Papers"},
+		},
+		{URL: "https://go.dev", Folder: []string{"Reading List", "Papers"}},
+		{URL: "https://www.rfc-editor.org/rfc/rfc3986", Title: "rfc", Folder: []string{"Refs"}},
+	}, bs)
+}
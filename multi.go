@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// MultiWalker walks multiple walkers concurrently, attributing each bookmark to the source it is walked from.
+type MultiWalker struct {
+	chanWalker
+	names   []string
+	walkers []Walker
+}
+
+// NewMultiWalker creates a MultiWalker walking walkers, where names[i] is the name of source walked by walkers[i].
+func NewMultiWalker(names []string, walkers []Walker, log *zap.SugaredLogger) *MultiWalker {
+	w := &MultiWalker{names: names, walkers: walkers}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *MultiWalker) walk() {
+	defer close(w.wchan)
+	// stop walking the walkers once w is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-w.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := range w.walkers {
+		wg.Add(1)
+		go func(name string, walker Walker) {
+			defer wg.Done()
+			defer walker.Stop()
+			for {
+				bmk, err := walker.Next(ctx)
+				if err == io.EOF {
+					return
+				} else if ctx.Err() != nil {
+					break
+				}
+				if bmk != nil {
+					bmk.Source = name
+				}
+				if err != nil {
+					err = fmt.Errorf("%s: %w", name, err)
+				}
+				if !w.send(&Result{bmk, err}) {
+					break
+				}
+			}
+			// w is stopped before walker is exhausted. Wait till walker exits, so that w exits after all of its
+			// walkers do
+			_ = exhaust(context.Background(), walker)
+		}(w.names[i], w.walkers[i])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiWalker(t *testing.T) {
+	foo, bar := &walkerMock{}, &walkerMock{}
+	foo.On("Next").Return(&Bookmark{URL: "https://foo.io"}, nil).Once()
+	foo.On("Next").Return(&Bookmark{URL: "https://qux.io"}, nil).Once()
+	foo.On("Next").Return((*Bookmark)(nil), io.EOF)
+	foo.On("Stop").Return()
+	bar.On("Next").Return((*Bookmark)(nil), errors.New("boom")).Once()
+	bar.On("Next").Return(&Bookmark{URL: "https://qux.io"}, nil).Once()
+	bar.On("Next").Return((*Bookmark)(nil), io.EOF)
+	bar.On("Stop").Return()
+
+	w := NewMultiWalker([]string{"foo.html", "bar.json"}, []Walker{foo, bar}, genTstLogger())
+	got, errs := []string{}, []string{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		got = append(got, b.Source+" "+b.URL)
+	}
+	sort.Strings(got)
+	assert.Equal(t, []string{"bar.json https://qux.io", "foo.html https://foo.io", "foo.html https://qux.io"}, got)
+	assert.Equal(t, []string{"bar.json: boom"}, errs)
+	foo.AssertExpectations(t)
+	bar.AssertExpectations(t)
+}
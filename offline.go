@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var errSkipped = &PingError{Reason: ReasonSkipped, Err: errors.New("status is not known without network request")}
+
+// LookupPinger looks up URL statuses from a known set of results instead of issuing any network request. URLs
+// absent from the results are considered Unknown.
+type LookupPinger struct {
+	Statuses map[string]PingStatus // keyed by canonical URL
+	Canon    CanonOptions          // options Statuses is canonicalized with
+}
+
+// Ping returns the known status of url, or Unknown along with an error if url status is not known.
+func (p *LookupPinger) Ping(_ context.Context, url string) (PingStatus, int, error) {
+	if s, ok := p.Statuses[CanonicalURL(url, p.Canon)]; ok {
+		return s, 0, nil
+	}
+	return Unknown, 0, errSkipped
+}
+
+// Record is the wash result of a URL previously output by mwsh.
+type Record struct {
+	Status PingStatus
+	Code   int       // response code, or 0 if unavailable
+	Reason ErrReason // category of ping error, or empty if there was none
+}
+
+// LoadResults reads URL statuses from r, which is the wash result previously output by mwsh, where each line
+// starts with "<status>\t<url>". The returned statuses are keyed by URL canonicalized with o.
+func LoadResults(r io.Reader, o CanonOptions) (map[string]PingStatus, error) {
+	recs, err := LoadRecords(r, o)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]PingStatus, len(recs))
+	for url, rec := range recs {
+		statuses[url] = rec.Status
+	}
+	return statuses, nil
+}
+
+// LoadRecords reads wash results from r, which is previously output by mwsh, where each line is in form of
+// "<status>\t<url>[\t<respCode>\t<error>...]". Blank lines and comments started with "#" are skipped, as LineWalker
+// does. The returned records are keyed by URL canonicalized with o.
+func LoadRecords(r io.Reader, o CanonOptions) (map[string]Record, error) {
+	recs := map[string]Record{}
+	s := bufio.NewScanner(r)
+	for ln := 1; s.Scan(); ln++ {
+		cols := splitLine(s.Text())
+		if cols == nil {
+			continue
+		}
+		if len(cols) < 2 {
+			return nil, fmt.Errorf("line %d: expect at least 2 tab-separated columns", ln)
+		}
+		var rec Record
+		var err error
+		if rec.Status, err = ParsePingStatus(cols[0]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", ln, err)
+		}
+		if len(cols) > 2 && cols[2] != "-" {
+			if rec.Code, err = strconv.Atoi(cols[2]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid response code: %w", ln, err)
+			}
+		}
+		if len(cols) > 3 && cols[3] != "-" {
+			rec.Reason = ErrReason(cols[3])
+		}
+		recs[CanonicalURL(cols[1], o)] = rec
+	}
+	return recs, s.Err()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadResults(t *testing.T) {
+	statuses, err := LoadResults(strings.NewReader(`alive	https://foo.io/
+# dead	https://bee.io
+dead	https://bar.io	410	http_4xx
+
+unknown	HTTPS://Qux.io	429	http_4xx
+`), CanonOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]PingStatus{
+		CanonicalURL("https://foo.io", CanonOptions{}): Alive,
+		CanonicalURL("https://bar.io", CanonOptions{}): Dead,
+		CanonicalURL("https://qux.io", CanonOptions{}): Unknown,
+	}, statuses)
+
+	for _, in := range []string{"alive https://foo.io", "zombie\thttps://foo.io"} {
+		_, err = LoadResults(strings.NewReader(in), CanonOptions{})
+		assert.NotNilf(t, err, "loading malformed results %q should have failed", in)
+	}
+}
+
+func TestLookupPinger(t *testing.T) {
+	p := &LookupPinger{Statuses: map[string]PingStatus{CanonicalURL("https://foo.io", CanonOptions{}): Dead}}
+	status, _, err := p.Ping(context.Background(), "https://foo.io/")
+	assert.Equal(t, Dead, status)
+	assert.Nil(t, err)
+	status, _, err = p.Ping(context.Background(), "https://bar.io/")
+	assert.Equal(t, Unknown, status)
+	assert.Equal(t, errSkipped, err)
+}
+
+func TestStartWashTillDone_SkipNetwork(t *testing.T) {
+	in := bytes.NewReader([]byte(`<DL><p>
+    <DT><A HREF="https://foo.io" ADD_DATE="1515361177">Foo</A>
+    <DT><A HREF="https://bar.io" ADD_DATE="1515361177">Bar</A>
+    <DT><A HREF="https://qux.io" ADD_DATE="1515361177">Qux</A>
+</DL><p>
+`))
+	// any network request panics the mock
+	dmock := &doerMock{}
+	out, cleaned := &bytes.Buffer{}, &bytes.Buffer{}
+	StartWashTillDone(context.Background(), in, out, dmock, 2, genTstLogger(),
+		WithWhitelist(Whitelist{CanonicalURL("https://foo.io", CanonOptions{}): {}}),
+		WithSkipNetwork(map[string]PingStatus{CanonicalURL("https://bar.io", CanonOptions{}): Dead}),
+		WithCleanedOutput(cleaned))
+	dmock.AssertExpectations(t)
+	assert.Contains(t, out.String(), "alive\thttps://foo.io\t-\t-\t-\t-\n")
+	assert.Contains(t, out.String(), "dead\thttps://bar.io\t-\t-\t-\t-\n")
+	assert.Contains(t, out.String(), "unknown\thttps://qux.io\t-\tskipped\t-\t-\n")
+	assert.Contains(t, cleaned.String(), "https://foo.io")
+	assert.NotContains(t, cleaned.String(), "https://bar.io")
+	assert.NotContains(t, cleaned.String(), "https://qux.io")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"net/mail"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// OPMLWalker walks OPML outlines, e.g. feed subscriptions exported by RSS readers. An outline holding a URL is a
+// bookmark, while the nesting of outlines makes up folders.
+type OPMLWalker struct {
+	chanWalker
+	r io.Reader
+}
+
+type opmlDoc struct {
+	Outlines []*opmlOutline `xml:"body>outline"`
+}
+
+// opmlOutline is an outline element of OPML, see http://opml.org/spec2.opml
+type opmlOutline struct {
+	Text        string         `xml:"text,attr"`
+	Title       string         `xml:"title,attr"`
+	XMLURL      string         `xml:"xmlUrl,attr"`
+	HTMLURL     string         `xml:"htmlUrl,attr"`
+	URL         string         `xml:"url,attr"`
+	Description string         `xml:"description,attr"`
+	Created     string         `xml:"created,attr"`
+	Outlines    []*opmlOutline `xml:"outline"`
+}
+
+func NewOPMLWalker(r io.Reader, log *zap.SugaredLogger) *OPMLWalker {
+	w := &OPMLWalker{r: r}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *OPMLWalker) walk() {
+	defer close(w.wchan)
+	doc := &opmlDoc{}
+	if err := xml.NewDecoder(w.r).Decode(doc); err != nil {
+		w.send(&Result{nil, err})
+		return
+	}
+	for _, o := range doc.Outlines {
+		if !w.walkOutline(o, nil) {
+			return
+		}
+	}
+}
+
+// walkOutline walks o whose parent folders are given by folder. It returns false if walker is stopped.
+func (w *OPMLWalker) walkOutline(o *opmlOutline, folder []string) bool {
+	title := o.Text
+	if title == "" {
+		title = o.Title
+	}
+	// feed subscriptions come with the feed URL and optionally the website URL, check the feed
+	url := o.XMLURL
+	if url == "" {
+		url = o.URL
+	}
+	if url == "" {
+		url = o.HTMLURL
+	}
+	if url != "" {
+		bmk := &Bookmark{URL: strings.TrimSpace(url), Title: title, Folder: folder, Description: o.Description}
+		if o.Created != "" {
+			// tolerate malformed date, which is not our concern
+			bmk.AddDate, _ = mail.ParseDate(o.Created)
+		}
+		w.log.Infow("created bookmark", "bookmark", bmk)
+		if !w.send(&Result{bmk, nil}) {
+			return false
+		}
+	}
+	if len(o.Outlines) == 0 {
+		return true
+	}
+	path := make([]string, len(folder), len(folder)+1)
+	copy(path, folder)
+	path = append(path, title)
+	for _, c := range o.Outlines {
+		if !w.walkOutline(c, path) {
+			return false
+		}
+	}
+	return true
+}
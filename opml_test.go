@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOPMLWalker(t *testing.T) {
+	in := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head>
+    <title>Subscriptions</title>
+  </head>
+  <body>
+    <outline text="Go" title="Go">
+      <outline type="rss" text="The Go Blog" xmlUrl="https://go.dev/blog/feed.atom" htmlUrl="https://go.dev/blog"
+        description="news &amp; views" created="Mon, 02 Jan 2006 15:04:05 GMT"/>
+      <outline text="Tools">
+        <outline type="link" text="gopls" url="https://pkg.go.dev/golang.org/x/tools/gopls"/>
+      </outline>
+    </outline>
+    <outline type="rss" title="Hacker News" xmlUrl="https://news.ycombinator.com/rss"/>
+    <outline text="Just a note"/>
+    <outline text="Site" htmlUrl="https://site.io/">
+      <outline text="Sub" url="https://site.io/sub"/>
+    </outline>
+  </body>
+</opml>
+`
+	w := NewOPMLWalker(strings.NewReader(in), genTstLogger())
+	bs := []*Bookmark{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		bs = append(bs, b)
+	}
+	assert.Equal(t, []*Bookmark{
+		{
+			URL:         "https://go.dev/blog/feed.atom",
+			Title:       "The Go Blog",
+			AddDate:     time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("GMT", 0)),
+			Folder:      []string{"Go"},
+			Description: "news & views",
+		},
+		{URL: "https://pkg.go.dev/golang.org/x/tools/gopls", Title: "gopls", Folder: []string{"Go", "Tools"}},
+		{URL: "https://news.ycombinator.com/rss", Title: "Hacker News"},
+		{URL: "https://site.io/", Title: "Site"},
+		{URL: "https://site.io/sub", Title: "Sub", Folder: []string{"Site"}},
+	}, bs)
+}
+
+func TestOPMLWalker_Malformed(t *testing.T) {
+	w := NewOPMLWalker(strings.NewReader(`<opml><body><outline text="a">`), genTstLogger())
+	b, err := w.Next(context.Background())
+	assert.Nil(t, b)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, io.EOF, err)
+	_, err = w.Next(context.Background())
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PinboardWalker walks the JSON export of Pinboard, which is an array of posts.
+type PinboardWalker struct {
+	chanWalker
+	r     io.Reader
+	posts []*pinboardPost
+}
+
+// pinboardPost is a bookmark in Pinboard export.
+type pinboardPost struct {
+	Href        string `json:"href"`
+	Description string `json:"description"` // title of bookmark
+	Extended    string `json:"extended"`    // description of bookmark
+	Time        string `json:"time"`
+	Shared      string `json:"shared"`
+	Tags        string `json:"tags"` // space-separated
+	// raw is the post object as exported, written back as is on cleaning, so that fields not parsed above, e.g.
+	// hash, meta and toread, are kept when the cleaned export is imported back into Pinboard
+	raw map[string]json.RawMessage
+	bmk *Bookmark
+}
+
+func (p *pinboardPost) UnmarshalJSON(b []byte) error {
+	type plain pinboardPost
+	if err := json.Unmarshal(b, (*plain)(p)); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &p.raw)
+}
+
+func (p *pinboardPost) MarshalJSON() ([]byte, error) {
+	return marshalJSON(p.raw, "")
+}
+
+func NewPinboardWalker(r io.Reader, log *zap.SugaredLogger) *PinboardWalker {
+	w := &PinboardWalker{r: r}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *PinboardWalker) walk() {
+	defer close(w.wchan)
+	if err := json.NewDecoder(w.r).Decode(&w.posts); err != nil {
+		w.send(&Result{nil, err})
+		return
+	}
+	for _, p := range w.posts {
+		bmk := &Bookmark{
+			URL:         p.Href,
+			Title:       p.Description,
+			Description: p.Extended,
+			Tags:        splitTags(p.Tags, " "),
+			Private:     p.Shared == "no",
+		}
+		if p.Time != "" {
+			var err error
+			if bmk.AddDate, err = time.Parse(time.RFC3339, p.Time); err != nil {
+				bmk.Warnings = append(bmk.Warnings, fmt.Sprintf("invalid time %q: %s", p.Time, err))
+			}
+		}
+		p.bmk = bmk
+		w.log.Infow("created bookmark", "bookmark", bmk)
+		if !w.send(&Result{bmk, nil}) {
+			return
+		}
+	}
+}
+
+// WriteCleaned writes the alive posts walked by w as a Pinboard JSON export.
+func (w *PinboardWalker) WriteCleaned(out io.Writer) error {
+	alive := make([]*pinboardPost, 0, len(w.posts))
+	for _, p := range w.posts {
+		if p.bmk != nil && p.bmk.Status == Alive {
+			alive = append(alive, p)
+		}
+	}
+	b, err := marshalJSON(alive, "")
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(b)
+	return err
+}
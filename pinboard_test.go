@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinboardWalker(t *testing.T) {
+	in := `[{"href":"https:\/\/foo.io\/","description":"Foo","extended":"about foo","meta":"abc","hash":"123",` +
+		`"time":"2021-03-04T05:06:07Z","shared":"no","toread":"yes","tags":"go dev"},` +
+		`{"href":"https:\/\/dead.io\/","description":"Dead","extended":"","meta":"def","hash":"456",` +
+		`"time":"junk","shared":"yes","toread":"no","tags":""}]`
+	w := NewPinboardWalker(strings.NewReader(in), genTstLogger())
+	bs := []*Bookmark{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		bs = append(bs, b)
+	}
+	assert.Equal(t, []*Bookmark{
+		{
+			URL:         "https://foo.io/",
+			Title:       "Foo",
+			AddDate:     time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+			Description: "about foo",
+			Tags:        []string{"go", "dev"},
+			Private:     true,
+		},
+		{
+			URL:      "https://dead.io/",
+			Title:    "Dead",
+			Warnings: []string{`invalid time "junk": parsing time "junk" as "2006-01-02T15:04:05Z07:00": cannot parse "junk" as "2006"`},
+		},
+	}, bs)
+
+	bs[0].Status, bs[1].Status = Alive, Dead
+	out := &bytes.Buffer{}
+	assert.Nil(t, w.WriteCleaned(out))
+	assert.Equal(t, `[{"description":"Foo","extended":"about foo","hash":"123","href":"https:\/\/foo.io\/","meta":"abc",`+
+		`"shared":"no","tags":"go dev","time":"2021-03-04T05:06:07Z","toread":"yes"}]`+"\n", out.String())
+}
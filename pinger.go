@@ -2,58 +2,111 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
 	urlpkg "net/url"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/avast/retry-go"
 	"go.uber.org/zap"
 )
 
-// Pinger checks whether a given URL is reachable or not.
+// Pinger checks whether a given URL is reachable or not. Besides the status, it returns the final response code
+// if any, and error encountered during check. Check is abandoned once ctx is done.
 // Pinger should be safe for concurrent use.
 type Pinger interface {
-	Ping(url string) (status PingStatus, err error)
+	Ping(ctx context.Context, url string) (status PingStatus, code int, err error)
 }
 
 // HTTPinger checks URLs in HTTP/S scheme.
 type HTTPinger struct {
-	Doer    Doer
-	Log     *zap.SugaredLogger
-	pingFns []pingFn
+	Doer Doer
+	Log  *zap.SugaredLogger
+	// MaxBackoff caps how long a host is backed off after it responds 429 Too Many Requests or 503 Service
+	// Unavailable, as well as how long a ping waits out such backoffs in total. A ping gives up once its host asks
+	// for longer via Retry-After. 0 means no cap.
+	MaxBackoff time.Duration
+	// Attempts is the max number of requests made to ping URL with each HTTP method.
+	Attempts uint
+	// RetryDelay is the delay before the first retry of a request. Delay doubles on each of the following retries.
+	RetryDelay time.Duration
+	// ThrottleRetries is the max number of retries of a request as host asks via Retry-After, which don't use up
+	// Attempts. It bounds a ping to a host keeping asking so, even if MaxBackoff doesn't.
+	ThrottleRetries uint
+
+	pingFns  []pingFn
+	backoffs *hostBackoffs
 }
 
+// defaults of HTTPinger settings
+const (
+	DefaultMaxBackoff = time.Minute
+	DefaultAttempts   = 3
+	DefaultRetryDelay = 100 * time.Millisecond
+
+	DefaultThrottleRetries = 5
+)
+
 // Doer is an abstraction over *http.Client.Do in std lib. It is to achieve better testability than
 // using the plain http.Client struct.
 type Doer interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
-// pingFn pings url and return ping status and error encountered.
-type pingFn func(url string) (PingStatus, error)
+// pingFn pings url and return ping status, response code and error encountered.
+type pingFn func(ctx context.Context, url string) (PingStatus, int, error)
 
 // NewHTTPinger returns a new HTTPinger.
 func NewHTTPinger(doer Doer, log *zap.SugaredLogger) *HTTPinger {
-	p := &HTTPinger{Doer: doer, Log: log}
+	p := &HTTPinger{
+		Doer:            doer,
+		Log:             log,
+		MaxBackoff:      DefaultMaxBackoff,
+		Attempts:        DefaultAttempts,
+		RetryDelay:      DefaultRetryDelay,
+		ThrottleRetries: DefaultThrottleRetries,
+		backoffs:        newHostBackoffs(),
+	}
 	p.pingFns = []pingFn{
-		func(url string) (PingStatus, error) { return p.ping(url, http.MethodHead) },
-		func(url string) (PingStatus, error) { return p.ping(url, http.MethodGet) },
+		func(ctx context.Context, url string) (PingStatus, int, error) {
+			return p.ping(ctx, url, http.MethodHead)
+		},
+		func(ctx context.Context, url string) (PingStatus, int, error) {
+			return p.ping(ctx, url, http.MethodGet)
+		},
 	}
 	return p
 }
 
-// Ping pings url to determine whether it is reachable or not.
-func (p *HTTPinger) Ping(url string) (status PingStatus, err error) {
+// Ping pings url to determine whether it is reachable or not. The returned error, if any, is a *PingError, unless
+// ping is abandoned before it starts, in which case errNotStarted is returned. The ping slot carried by ctx, if
+// any, is held while pinging.
+func (p *HTTPinger) Ping(ctx context.Context, url string) (status PingStatus, code int, err error) {
+	if s := pingSlotOf(ctx); s != nil {
+		if !s.acquire(ctx) {
+			if ctx.Err() != nil {
+				return Unknown, 0, classify(ctx.Err())
+			}
+			return Unknown, 0, errNotStarted
+		}
+		defer s.release()
+	}
 	for _, f := range p.pingFns {
-		status, err = f(url)
+		status, code, err = f(ctx, url)
 		if terminal(status, err) {
-			return
+			break
 		}
 	}
-	return
+	return status, code, classify(err)
 }
 
 // terminal tells if we need to continue pinging(with a different strategy) based on ping result
@@ -68,36 +121,127 @@ func terminal(status PingStatus, err error) bool {
 	return !errOrStatusRetryable(err)
 }
 
-func (p *HTTPinger) ping(url, method string) (PingStatus, error) {
-	req, err := http.NewRequest(method, url, nil)
+func (p *HTTPinger) ping(ctx context.Context, url, method string) (PingStatus, int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return Dead, err
+		return Dead, 0, &PingError{Reason: ReasonBadURL, Err: err}
 	}
 	req.Header.Add("User-Agent", randUserAgent())
+	host := strings.ToLower(req.URL.Hostname())
 	var resp *http.Response
+	var n uint
+	// total backoff asked by host via Retry-After and retries done so, which don't use up attempts
+	var backedOff time.Duration
+	var throttled uint
 	err = retry.Do(
 		func() error {
-			resp, err = p.Doer.Do(req)
-			if err == nil && !alive(resp.StatusCode) {
+			// wait out the exponential backoff between retries here instead of in retry.Do, so that waiting is
+			// abandoned once ctx is done, and doesn't hold back pings of the other hosts
+			if n++; n > 1 {
+				if err := idle(ctx, p.RetryDelay<<(n-2)); err != nil {
+					return err
+				}
+			}
+			for {
+				// hold off while host is backed off, be it due to this ping or the others
+				if err := p.backoffs.wait(ctx, host); err != nil {
+					return err
+				}
+				resp, err = p.Doer.Do(req)
+				if err != nil {
+					return err
+				}
+				code := resp.StatusCode
+				if alive(code) {
+					p.backoffs.reset(host)
+					return nil
+				}
 				// make sure connection can be reused for successive retries, if any
 				p.blackhole(resp.Body)
-				err = statusNotAlive(resp.StatusCode)
+				if code != http.StatusTooManyRequests && code != http.StatusServiceUnavailable {
+					return statusNotAlive(code)
+				}
+				after := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+				d := p.backoffs.throttle(host, after, p.RetryDelay, p.MaxBackoff)
+				p.Log.Infow("backing off host", "host", host, "code", code, "backoff", d)
+				if after <= 0 {
+					// host doesn't tell when to come back. Retry as usual
+					return statusNotAlive(code)
+				}
+				if p.MaxBackoff > 0 && backedOff+after > p.MaxBackoff {
+					p.Log.Infow("host asks to back off for longer than allowed. Give up", "host", host,
+						"backoff", after, "backedOff", backedOff)
+					return retry.Unrecoverable(statusNotAlive(code))
+				}
+				if throttled >= p.ThrottleRetries {
+					p.Log.Infow("host asks to back off more times than allowed. Give up", "host", host,
+						"retries", throttled)
+					return retry.Unrecoverable(statusNotAlive(code))
+				}
+				// come back as host tells, once it's backed off
+				backedOff += d
+				throttled++
 			}
-			return err
 		},
 		retry.RetryIf(errOrStatusRetryable),
 		retry.LastErrorOnly(true),
-		retry.Attempts(3),
-		retry.Delay(100*time.Millisecond),   // default delay
-		retry.DelayType(retry.BackOffDelay), // exponential backoff
+		retry.Attempts(p.Attempts),
+		retry.Delay(0), // retried func waits by itself
+		retry.DelayType(retry.FixedDelay),
 	)
 	if _, ok := err.(statusNotAlive); err != nil && !ok {
-		return Unknown, err
+		return Unknown, 0, err
 	}
 	// no point to read up body as bookmarks are usually unique to each other, plus we've done all retries
 	_ = resp.Body.Close()
 	// make error, be it due to network / bad response,  accessible to users
-	return check(resp.StatusCode), err
+	return check(resp.StatusCode), resp.StatusCode, err
+}
+
+// retryAfter parses value of Retry-After header, in either delta-seconds or HTTP-date form, into how long to wait
+// from now. It returns 0 if value is absent, invalid or already past.
+func retryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if secs, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	t, err := http.ParseTime(value)
+	if err != nil || !t.After(now) {
+		return 0
+	}
+	return t.Sub(now)
+}
+
+// sleep pauses for d, or till ctx is done in which case it returns ctx.Err().
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// idle is sleep giving up the ping slot carried by ctx, if any, till it's over, so that pings of the other hosts
+// go on meanwhile.
+func idle(ctx context.Context, d time.Duration) error {
+	s := pingSlotOf(ctx)
+	if d <= 0 || s == nil {
+		return sleep(ctx, d)
+	}
+	s.release()
+	if err := sleep(ctx, d); err != nil {
+		return err
+	}
+	if !s.acquire(ctx) {
+		return ctx.Err()
+	}
+	return nil
 }
 
 func errOrStatusRetryable(err error) bool {
@@ -128,6 +272,110 @@ func (s statusNotAlive) Error() string {
 	return http.StatusText(int(s))
 }
 
+// ErrReason is the category of error encountered when pinging URL. Reasons come from a fixed list so that they
+// are easy to group by for output consumers.
+type ErrReason string
+
+const (
+	ReasonBadURL           ErrReason = "bad_url"
+	ReasonDNSNotFound      ErrReason = "dns_not_found"
+	ReasonDNSError         ErrReason = "dns_error"
+	ReasonConnRefused      ErrReason = "conn_refused"
+	ReasonConnReset        ErrReason = "conn_reset"
+	ReasonTLSError         ErrReason = "tls_error"
+	ReasonTimeout          ErrReason = "timeout"
+	ReasonTooManyRedirects ErrReason = "too_many_redirects"
+	ReasonHTTP3xx          ErrReason = "http_3xx"
+	ReasonHTTP4xx          ErrReason = "http_4xx"
+	ReasonHTTP5xx          ErrReason = "http_5xx"
+	ReasonSkipped          ErrReason = "skipped"
+	ReasonNetworkError     ErrReason = "network_error"
+	ReasonCanceled         ErrReason = "canceled"
+	ReasonOther            ErrReason = "other"
+)
+
+// PingError is the error encountered when pinging URL, along with its category.
+type PingError struct {
+	Reason ErrReason
+	Err    error
+}
+
+func (e *PingError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Err)
+}
+
+func (e *PingError) Unwrap() error {
+	return e.Err
+}
+
+// classify wraps err into a *PingError, unless err is nil or already one.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*PingError); ok {
+		return err
+	}
+	return &PingError{Reason: reasonOf(err), Err: err}
+}
+
+// reasonOf returns the category of err.
+func reasonOf(err error) ErrReason {
+	var pe *PingError
+	if errors.As(err, &pe) {
+		return pe.Reason
+	}
+	var sna statusNotAlive
+	if errors.As(err, &sna) {
+		switch {
+		case sna >= 500:
+			return ReasonHTTP5xx
+		case sna >= 400:
+			return ReasonHTTP4xx
+		case sna >= 300:
+			return ReasonHTTP3xx
+		default:
+			return ReasonOther
+		}
+	}
+	if errors.Is(err, context.Canceled) {
+		return ReasonCanceled
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsNotFound {
+			return ReasonDNSNotFound
+		} else if dnsErr.IsTimeout {
+			return ReasonTimeout
+		}
+		return ReasonDNSError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ReasonTimeout
+	}
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ReasonConnRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ReasonConnReset
+	}
+	// the following errors are not exposed as types by std lib
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "x509: ") || strings.Contains(msg, "tls: "):
+		return ReasonTLSError
+	case strings.Contains(msg, "stopped after") && strings.Contains(msg, "redirects"):
+		return ReasonTooManyRedirects
+	case strings.Contains(msg, "unsupported protocol scheme"):
+		return ReasonBadURL
+	}
+	if _, ok := err.(*urlpkg.Error); ok {
+		return ReasonNetworkError
+	}
+	return ReasonOther
+}
+
 // check return ping status based on ping response status code.
 func check(code int) PingStatus {
 	if _, ok := dead[code]; ok {
@@ -153,6 +401,16 @@ func (s PingStatus) String() string {
 	return pingStatuses[int(s)]
 }
 
+// ParsePingStatus returns the PingStatus whose string form is s.
+func ParsePingStatus(s string) (PingStatus, error) {
+	for i, v := range pingStatuses {
+		if strings.EqualFold(s, v) {
+			return PingStatus(i), nil
+		}
+	}
+	return Unknown, fmt.Errorf("invalid ping status %q", s)
+}
+
 // alive returns true if respon status code indicates a reachable URL
 func alive(code int) bool {
 	return code < 300 && code >= 200
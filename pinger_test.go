@@ -2,10 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"syscall"
 	"testing"
+	"time"
 
 	urlpkg "net/url"
 
@@ -97,9 +104,10 @@ func TestHTTPinger_alive(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
 			pinger := NewHTTPinger(c.dmock, log)
-			status, err := pinger.Ping(url)
+			status, code, err := pinger.Ping(context.Background(), url)
 			c.dmock.AssertExpectations(t)
 			assert.Equal(t, Alive, status)
+			assert.Equal(t, http.StatusOK, code)
 			assert.Equal(t, c.expErr, err)
 		})
 	}
@@ -108,11 +116,12 @@ func TestHTTPinger_alive(t *testing.T) {
 func TestHTTPinger_dead(t *testing.T) {
 	url := "https://dead.url"
 	type tcase struct {
-		name   string
-		dmock  *doerMock
-		expErr error
+		name    string
+		dmock   *doerMock
+		expCode int
+		expErr  error
 	}
-	genCase := func(code int) tcase {
+	genCase := func(code int, reason ErrReason) tcase {
 		return tcase{
 			name: http.StatusText(code),
 			dmock: func() *doerMock {
@@ -122,17 +131,18 @@ func TestHTTPinger_dead(t *testing.T) {
 				}).Return(genResp(code), nil).Once()
 				return m
 			}(),
-			expErr: statusNotAlive(code),
+			expCode: code,
+			expErr:  &PingError{Reason: reason, Err: statusNotAlive(code)},
 		}
 	}
 	tcs := []tcase{
-		genCase(http.StatusGone),
-		genCase(http.StatusConflict),
-		genCase(http.StatusRequestEntityTooLarge),
-		genCase(http.StatusRequestURITooLong),
-		genCase(http.StatusUnprocessableEntity),
-		genCase(http.StatusFailedDependency),
-		genCase(http.StatusNotImplemented),
+		genCase(http.StatusGone, ReasonHTTP4xx),
+		genCase(http.StatusConflict, ReasonHTTP4xx),
+		genCase(http.StatusRequestEntityTooLarge, ReasonHTTP4xx),
+		genCase(http.StatusRequestURITooLong, ReasonHTTP4xx),
+		genCase(http.StatusUnprocessableEntity, ReasonHTTP4xx),
+		genCase(http.StatusFailedDependency, ReasonHTTP4xx),
+		genCase(http.StatusNotImplemented, ReasonHTTP5xx),
 	}
 	log := genTstLogger()
 	for _, cs := range tcs {
@@ -140,9 +150,10 @@ func TestHTTPinger_dead(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
 			pinger := NewHTTPinger(c.dmock, log)
-			status, err := pinger.Ping(url)
+			status, code, err := pinger.Ping(context.Background(), url)
 			c.dmock.AssertExpectations(t)
 			assert.Equal(t, Dead, status)
+			assert.Equal(t, c.expCode, code)
 			assert.Equal(t, c.expErr, err)
 		})
 	}
@@ -156,9 +167,9 @@ func TestHTTPinger_dead_BadURL(t *testing.T) {
 	for _, url := range badUrls {
 		t.Run(url, func(t *testing.T) {
 			pinger := NewHTTPinger(nil, log)
-			status, err := pinger.Ping(url)
+			status, _, err := pinger.Ping(context.Background(), url)
 			assert.Equal(t, Dead, status)
-			assert.NotEmpty(t, err)
+			assert.Equal(t, ReasonBadURL, reasonOf(err))
 		})
 	}
 }
@@ -167,11 +178,12 @@ func TestHTTPinger_unknown(t *testing.T) {
 	// TODO Table-driven test: Get the 1st case running and then all others will follow
 	url := "https://unknown.url"
 	type tcase struct {
-		name   string
-		dmock  *doerMock
-		expErr error
+		name    string
+		dmock   *doerMock
+		expCode int
+		expErr  error
 	}
-	genCase := func(name string, code int, doErr error, headTries, getTries int) tcase {
+	genCase := func(name string, code int, doErr error, reason ErrReason, headTries, getTries int) tcase {
 		return tcase{
 			name: name,
 			dmock: func() *doerMock {
@@ -184,13 +196,14 @@ func TestHTTPinger_unknown(t *testing.T) {
 				}).Return(genResp(code), doErr).Times(getTries)
 				return m
 			}(),
-			expErr: doErr,
+			expCode: code,
+			expErr:  &PingError{Reason: reason, Err: doErr},
 		}
 	}
 	tcs := []tcase{
-		genCase("NetErrNonRetryable", 0, &urlpkg.Error{Err: errMock{Tmout: false, Temp: false}}, 1, 0),
-		genCase("NetErrTimeout", 0, &urlpkg.Error{Err: errMock{Tmout: true}}, 3, 3),
-		genCase("NetErrTemp", 0, &urlpkg.Error{Err: errMock{Temp: true}}, 3, 3),
+		genCase("NetErrNonRetryable", 0, &urlpkg.Error{Err: errMock{Tmout: false, Temp: false}}, ReasonNetworkError, 1, 0),
+		genCase("NetErrTimeout", 0, &urlpkg.Error{Err: errMock{Tmout: true}}, ReasonTimeout, 3, 3),
+		genCase("NetErrTemp", 0, &urlpkg.Error{Err: errMock{Temp: true}}, ReasonNetworkError, 3, 3),
 	}
 	// add cases where we exhausted retries on retryable status code
 	for code, tries := range map[int][]int{
@@ -203,7 +216,11 @@ func TestHTTPinger_unknown(t *testing.T) {
 		http.StatusGatewayTimeout:      {3, 3},
 		http.StatusInsufficientStorage: {3, 3},
 	} {
-		tcs = append(tcs, genCase(http.StatusText(code), code, statusNotAlive(code), tries[0], tries[1]))
+		reason := ReasonHTTP4xx
+		if code >= 500 {
+			reason = ReasonHTTP5xx
+		}
+		tcs = append(tcs, genCase(http.StatusText(code), code, statusNotAlive(code), reason, tries[0], tries[1]))
 	}
 	log := genTstLogger()
 	for _, cs := range tcs {
@@ -211,9 +228,10 @@ func TestHTTPinger_unknown(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
 			pinger := NewHTTPinger(c.dmock, log)
-			status, err := pinger.Ping(url)
+			status, code, err := pinger.Ping(context.Background(), url)
 			c.dmock.AssertExpectations(t)
 			assert.Equal(t, Unknown, status)
+			assert.Equal(t, c.expCode, code)
 			assert.Equal(t, c.expErr, err)
 		})
 	}
@@ -262,3 +280,166 @@ func genTstLogger() *zap.SugaredLogger {
 	}
 	return lg.Sugar()
 }
+
+func TestReasonOf(t *testing.T) {
+	urlErr := func(err error) error { return &urlpkg.Error{Op: "Head", URL: "https://foo.io", Err: err} }
+	tcs := []struct {
+		name string
+		err  error
+		exp  ErrReason
+	}{
+		{"DNSNotFound", urlErr(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}), ReasonDNSNotFound},
+		{"DNSError", urlErr(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "server misbehaving"}}), ReasonDNSError},
+		{"ConnRefused", urlErr(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), ReasonConnRefused},
+		{"ConnReset", urlErr(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), ReasonConnReset},
+		{"Timeout", urlErr(errMock{Tmout: true}), ReasonTimeout},
+		{"TLSError", urlErr(x509.UnknownAuthorityError{}), ReasonTLSError},
+		{"TooManyRedirects", urlErr(errors.New("stopped after 10 redirects")), ReasonTooManyRedirects},
+		{"UnsupportedScheme", urlErr(errors.New("unsupported protocol scheme \"javascript\"")), ReasonBadURL},
+		{"NetworkError", urlErr(errMock{Temp: true}), ReasonNetworkError},
+		{"HTTP3xx", statusNotAlive(http.StatusMultipleChoices), ReasonHTTP3xx},
+		{"HTTP4xx", statusNotAlive(http.StatusForbidden), ReasonHTTP4xx},
+		{"HTTP5xx", statusNotAlive(http.StatusBadGateway), ReasonHTTP5xx},
+		{"Canceled", urlErr(context.Canceled), ReasonCanceled},
+		{"PingError", &PingError{Reason: ReasonSkipped, Err: errors.New("boom!")}, ReasonSkipped},
+		{"Other", errors.New("boom!"), ReasonOther},
+	}
+	for _, c := range tcs {
+		assert.Equalf(t, c.exp, reasonOf(c.err), "reason of %s", c.name)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	tcs := []struct {
+		name  string
+		value string
+		exp   time.Duration
+	}{
+		{"DeltaSeconds", "120", 2 * time.Minute},
+		{"DeltaSecondsPadded", " 5 ", 5 * time.Second},
+		{"HTTPDate", "Mon, 01 Mar 2021 12:00:30 GMT", 30 * time.Second},
+		{"HTTPDateRFC850", "Monday, 01-Mar-21 12:01:00 GMT", time.Minute},
+		{"HTTPDatePast", "Mon, 01 Mar 2021 11:00:00 GMT", 0},
+		{"Absent", "", 0},
+		{"Negative", "-1", 0},
+		{"Invalid", "soon", 0},
+	}
+	for _, c := range tcs {
+		assert.Equalf(t, c.exp, retryAfter(c.value, now), "Retry-After of %s", c.name)
+	}
+}
+
+func TestHTTPinger_RetryAfter(t *testing.T) {
+	url := "https://busy.io/a"
+	throttled := genResp(http.StatusTooManyRequests)
+	throttled.Header = http.Header{"Retry-After": []string{"1"}}
+	m := &doerMock{}
+	m.On("Do", mock.Anything).Run(func(args mock.Arguments) {
+		reqAsExpected(t, args.Get(0).(*http.Request), url, http.MethodHead)
+	}).Return(throttled, nil).Twice()
+	m.On("Do", mock.Anything).Run(func(args mock.Arguments) {
+		reqAsExpected(t, args.Get(0).(*http.Request), url, http.MethodHead)
+	}).Return(genResp(http.StatusOK), nil).Once()
+	pinger := NewHTTPinger(m, genTstLogger())
+	// retries asked by host via Retry-After don't use up attempts
+	pinger.Attempts = 1
+	start := time.Now()
+	status, code, err := pinger.Ping(context.Background(), url)
+	elapsed := time.Since(start)
+	m.AssertExpectations(t)
+	assert.Equal(t, Alive, status)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, err)
+	assert.True(t, elapsed >= 2*time.Second, "expect to back off for 2s but took %s", elapsed)
+}
+
+func TestHTTPinger_RetryAfterTooLong(t *testing.T) {
+	url := "https://busy.io/a"
+	throttled := genResp(http.StatusServiceUnavailable)
+	throttled.Header = http.Header{"Retry-After": []string{"3600"}}
+	m := &doerMock{}
+	m.On("Do", mock.Anything).Return(throttled, nil)
+	pinger := NewHTTPinger(m, genTstLogger())
+	pinger.MaxBackoff = 300 * time.Millisecond
+	start := time.Now()
+	status, code, err := pinger.Ping(context.Background(), url)
+	elapsed := time.Since(start)
+	// ping gives up right away with each HTTP method, though the other pings of host are backed off till the cap
+	m.AssertNumberOfCalls(t, "Do", 2)
+	assert.Equal(t, Unknown, status)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, &PingError{Reason: ReasonHTTP5xx, Err: statusNotAlive(http.StatusServiceUnavailable)}, err)
+	assert.True(t, elapsed < time.Second, "expect to give up without waiting for long but took %s", elapsed)
+}
+
+func TestHTTPinger_RetryAfterTooManyTimes(t *testing.T) {
+	throttled := genResp(http.StatusTooManyRequests)
+	throttled.Header = http.Header{"Retry-After": []string{"1"}}
+	m := &doerMock{}
+	m.On("Do", mock.Anything).Return(throttled, nil)
+	pinger := NewHTTPinger(m, genTstLogger())
+	// no cap of backoff, so host keeping asking to back off is given up by count only
+	pinger.MaxBackoff, pinger.ThrottleRetries = 0, 1
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	status, code, err := pinger.Ping(ctx, "https://busy.io/a")
+	// each HTTP method retries once as asked
+	m.AssertNumberOfCalls(t, "Do", 4)
+	assert.Equal(t, Unknown, status)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.Equal(t, &PingError{Reason: ReasonHTTP4xx, Err: statusNotAlive(http.StatusTooManyRequests)}, err)
+}
+
+func TestHTTPinger_RetryAfterSharedByHost(t *testing.T) {
+	throttled := genResp(http.StatusServiceUnavailable)
+	throttled.Header = http.Header{"Retry-After": []string{"1"}}
+	m := &doerMock{}
+	m.On("Do", mock.MatchedBy(func(req *http.Request) bool { return req.URL.Path == "/a" })).
+		Return(throttled, nil).Once()
+	m.On("Do", mock.Anything).Return(genResp(http.StatusOK), nil)
+	pinger := NewHTTPinger(m, genTstLogger())
+	_, _, err := pinger.Ping(context.Background(), "https://busy.io/a")
+	assert.Nil(t, err)
+	// the other pings to the same host wait out the backoff as well, but not the pings to other hosts
+	start := time.Now()
+	pinger.backoffs.throttle("busy.io", time.Second, 0, 0)
+	_, _, err = pinger.Ping(context.Background(), "https://other.io/")
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "pings to other hosts should not have backed off")
+	_, _, err = pinger.Ping(context.Background(), "https://BUSY.io/b")
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= time.Second, "pings to the same host should have backed off")
+	m.AssertExpectations(t)
+}
+
+func TestHTTPinger_Attempts(t *testing.T) {
+	url := "https://flaky.io"
+	m := &doerMock{}
+	m.On("Do", mock.Anything).Return(genResp(http.StatusInternalServerError), nil).Times(2)
+	pinger := NewHTTPinger(m, genTstLogger())
+	pinger.Attempts, pinger.RetryDelay = 1, time.Hour
+	status, code, err := pinger.Ping(context.Background(), url)
+	// one request per HTTP method, without waiting for retry
+	m.AssertExpectations(t)
+	assert.Equal(t, Unknown, status)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, &PingError{Reason: ReasonHTTP5xx, Err: statusNotAlive(http.StatusInternalServerError)}, err)
+}
+
+func TestHTTPinger_Canceled(t *testing.T) {
+	throttled := genResp(http.StatusTooManyRequests)
+	throttled.Header = http.Header{"Retry-After": []string{"3600"}}
+	m := &doerMock{}
+	m.On("Do", mock.Anything).Return(throttled, nil).Once()
+	pinger := NewHTTPinger(m, genTstLogger())
+	pinger.MaxBackoff = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	status, _, err := pinger.Ping(ctx, "https://busy.io")
+	m.AssertExpectations(t)
+	assert.True(t, time.Since(start) < time.Second, "ping should have been abandoned once ctx is done")
+	assert.Equal(t, Unknown, status)
+	assert.Equal(t, &PingError{Reason: ReasonCanceled, Err: context.Canceled}, err)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/html"
+)
+
+const h1Tag = "h1"
+
+// titles of Pocket lists, the same as what Pocket HTML export uses as section headings
+var pocketListTitles = map[string]string{
+	"unread":  "Unread",
+	"archive": "Read Archive",
+}
+
+// PocketWalker walks the HTML export of Pocket, which lists bookmarks in sections, e.g. "Unread" and
+// "Read Archive". Sections make up folders.
+type PocketWalker struct {
+	chanWalker
+	tokenizer *html.Tokenizer
+	title     string
+	sections  []*pocketSection
+}
+
+type pocketSection struct {
+	title string
+	items []*Bookmark
+}
+
+func NewPocketWalker(r io.Reader, log *zap.SugaredLogger) *PocketWalker {
+	w := &PocketWalker{tokenizer: html.NewTokenizer(r)}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *PocketWalker) walk() {
+	defer close(w.wchan)
+	z := w.tokenizer
+	// bookmarks before any section heading go to an untitled section
+	section := &pocketSection{}
+	w.sections = append(w.sections, section)
+	// text receives the text of element being walked, if any
+	var text *string
+	var bmk *Bookmark
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				w.send(&Result{nil, err})
+			}
+			return
+		case html.StartTagToken:
+			t := z.Token()
+			switch t.Data {
+			case titleTag:
+				text = &w.title
+			case h1Tag:
+				section = &pocketSection{}
+				w.sections = append(w.sections, section)
+				text = &section.title
+			case anchorTag:
+				bmk = genPocketBookmark(t.Attr)
+				if section.title != "" {
+					bmk.Folder = []string{section.title}
+				}
+				section.items = append(section.items, bmk)
+				text = &bmk.Title
+			}
+		case html.EndTagToken:
+			t := z.Token()
+			switch t.Data {
+			case titleTag, h1Tag, anchorTag:
+				if text != nil {
+					*text = collapseSpace(*text)
+					text = nil
+				}
+			}
+			if t.Data != anchorTag || bmk == nil {
+				continue
+			}
+			w.log.Infow("created bookmark", "bookmark", bmk)
+			if !w.send(&Result{bmk, nil}) {
+				return
+			}
+			bmk = nil
+		case html.TextToken:
+			if text != nil {
+				*text += z.Token().Data
+			}
+		}
+	}
+}
+
+func genPocketBookmark(attr []html.Attribute) *Bookmark {
+	bmk := &Bookmark{Attr: attr}
+	for _, a := range attr {
+		switch a.Key {
+		case "href":
+			bmk.URL = a.Val
+		case "time_added":
+			var err error
+			if bmk.AddDate, err = parseUnixSeconds(a.Val); err != nil {
+				bmk.Warnings = append(bmk.Warnings, fmt.Sprintf("invalid %s %q: %s", a.Key, a.Val, err))
+			}
+		case "tags":
+			bmk.Tags = splitTags(a.Val, ",")
+		}
+	}
+	return bmk
+}
+
+// WriteCleaned writes the alive bookmarks walked by w as a Pocket HTML export.
+func (w *PocketWalker) WriteCleaned(out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	title := w.title
+	if title == "" {
+		title = "Pocket Export"
+	}
+	fmt.Fprintf(bw, "<!DOCTYPE html>\n<html>\n\t<!--So long and thanks for all the fish-->\n\t<head>\n"+
+		"\t\t<meta http-equiv=\"Content-Type\" content=\"text/html; charset=UTF-8\" />\n"+
+		"\t\t<title>%s</title>\n\t</head>\n\t<body>\n", html.EscapeString(title))
+	written := 0
+	for _, s := range w.sections {
+		if s.title == "" && len(s.items) == 0 {
+			continue
+		}
+		if written++; written > 1 {
+			fmt.Fprint(bw, "\n")
+		}
+		if s.title != "" {
+			fmt.Fprintf(bw, "\t\t<h1>%s</h1>\n", html.EscapeString(s.title))
+		}
+		fmt.Fprint(bw, "\t\t<ul>\n")
+		for _, b := range s.items {
+			if b.Status != Alive {
+				continue
+			}
+			fmt.Fprintf(bw, "\t\t\t<li><a%s>%s</a></li>\n", pocketAttrs(b.Attr), html.EscapeString(b.Title))
+		}
+		fmt.Fprint(bw, "\t\t</ul>\n")
+	}
+	fmt.Fprint(bw, "\t</body>\n</html>\n")
+	return bw.Flush()
+}
+
+func pocketAttrs(attr []html.Attribute) string {
+	sb := &strings.Builder{}
+	for _, a := range attr {
+		fmt.Fprintf(sb, ` %s="%s"`, a.Key, html.EscapeString(a.Val))
+	}
+	return sb.String()
+}
+
+// PocketCSVWalker walks the CSV export of Pocket, whose header is "title,url,time_added,tags,status". Pocket lists
+// given by status make up folders.
+type PocketCSVWalker struct {
+	*csvWalker
+}
+
+func NewPocketCSVWalker(r io.Reader, log *zap.SugaredLogger) *PocketCSVWalker {
+	return &PocketCSVWalker{newCSVWalker(r, log, genPocketCSVBookmark)}
+}
+
+func genPocketCSVBookmark(row csvRow) *Bookmark {
+	bmk := &Bookmark{URL: row("url"), Title: row("title"), Tags: splitTags(row("tags"), "|")}
+	if t := row("time_added"); t != "" {
+		var err error
+		if bmk.AddDate, err = parseUnixSeconds(t); err != nil {
+			bmk.Warnings = append(bmk.Warnings, fmt.Sprintf("invalid time_added %q: %s", t, err))
+		}
+	}
+	if s := row("status"); s != "" {
+		if title, ok := pocketListTitles[s]; ok {
+			s = title
+		}
+		bmk.Folder = []string{s}
+	}
+	return bmk
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestPocketWalker(t *testing.T) {
+	f, err := os.Open("testdata/netscape/pocket.html")
+	require.Nil(t, err)
+	defer f.Close()
+	w := NewPocketWalker(f, genTstLogger())
+	bs := []*Bookmark{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		bs = append(bs, b)
+	}
+	require.Len(t, bs, 4)
+	assert.Equal(t, &Bookmark{
+		URL:     "https://martinfowler.com/articles/microservices.html",
+		Title:   "Microservices – a definition of this new architectural term",
+		AddDate: time.Unix(1609459201, 0),
+		Folder:  []string{"Unread"},
+		Tags:    []string{"architecture", "reading"},
+		Attr: []html.Attribute{
+			{Key: "href", Val: "https://martinfowler.com/articles/microservices.html"},
+			{Key: "time_added", Val: "1609459201"},
+			{Key: "tags", Val: "architecture,reading"},
+		},
+	}, bs[1])
+	assert.Equal(t, []string{"Read Archive"}, bs[2].Folder)
+
+	bs[1].Status, bs[2].Status = Alive, Alive
+	out := &bytes.Buffer{}
+	assert.Nil(t, w.WriteCleaned(out))
+	assert.Equal(t, `<!DOCTYPE html>
+<html>
+	<!--So long and thanks for all the fish-->
+	<head>
+		<meta http-equiv="Content-Type" content="text/html; charset=UTF-8" />
+		<title>Pocket Export</title>
+	</head>
+	<body>
+		<h1>Unread</h1>
+		<ul>
+			<li><a href="https://martinfowler.com/articles/microservices.html" time_added="1609459201" tags="architecture,reading">Microservices – a definition of this new architectural term</a></li>
+		</ul>
+
+		<h1>Read Archive</h1>
+		<ul>
+			<li><a href="https://www.paulgraham.com/avg.html" time_added="1609459202" tags="">Beating the Averages</a></li>
+		</ul>
+	</body>
+</html>
+`, out.String())
+}
+
+func TestPocketCSVWalker(t *testing.T) {
+	in := `title,url,time_added,tags,status
+"Foo, the site",https://foo.io/,1609459200,go|dev,unread
+Dead,https://dead.io/,junk,,archive
+`
+	w := NewPocketCSVWalker(strings.NewReader(in), genTstLogger())
+	bs := []*Bookmark{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		bs = append(bs, b)
+	}
+	assert.Equal(t, []*Bookmark{
+		{
+			URL:     "https://foo.io/",
+			Title:   "Foo, the site",
+			AddDate: time.Unix(1609459200, 0),
+			Folder:  []string{"Unread"},
+			Tags:    []string{"go", "dev"},
+		},
+		{
+			URL:      "https://dead.io/",
+			Title:    "Dead",
+			Folder:   []string{"Read Archive"},
+			Warnings: []string{`invalid time_added "junk": strconv.ParseInt: parsing "junk": invalid syntax`},
+		},
+	}, bs)
+
+	bs[0].Status, bs[1].Status = Alive, Dead
+	out := &bytes.Buffer{}
+	assert.Nil(t, w.WriteCleaned(out))
+	assert.Equal(t, `title,url,time_added,tags,status
+"Foo, the site",https://foo.io/,1609459200,go|dev,unread
+`, out.String())
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RaindropWalker walks the CSV export of Raindrop.io, whose header is
+// "id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite".
+type RaindropWalker struct {
+	*csvWalker
+}
+
+func NewRaindropWalker(r io.Reader, log *zap.SugaredLogger) *RaindropWalker {
+	return &RaindropWalker{newCSVWalker(r, log, genRaindropBookmark)}
+}
+
+func genRaindropBookmark(row csvRow) *Bookmark {
+	bmk := &Bookmark{URL: row("url"), Title: row("title"), Tags: splitTags(row("tags"), ",")}
+	// note is written by user, while excerpt is extracted from the page
+	if bmk.Description = row("note"); bmk.Description == "" {
+		bmk.Description = row("excerpt")
+	}
+	if f := row("folder"); f != "" {
+		bmk.Folder = strings.Split(f, "/")
+	}
+	if c := row("created"); c != "" {
+		var err error
+		if bmk.AddDate, err = time.Parse(time.RFC3339, c); err != nil {
+			bmk.Warnings = append(bmk.Warnings, fmt.Sprintf("invalid created %q: %s", c, err))
+		}
+	}
+	return bmk
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRaindropWalker(t *testing.T) {
+	in := "\ufeffid,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite\n" +
+		`101,Foo,my note,page excerpt,https://foo.io/,Dev/Go,"go, dev",2021-03-04T05:06:07.000Z,,,true` + "\n" +
+		`102,Bar,,bar excerpt,https://bar.io/,Unsorted,,2021-03-04T05:06:08.000Z,,,false` + "\n" +
+		`103,Short row,,,https://short.io/` + "\n"
+	w := NewRaindropWalker(strings.NewReader(in), genTstLogger())
+	bs := []*Bookmark{}
+	for {
+		b, err := w.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		bs = append(bs, b)
+	}
+	assert.Equal(t, []*Bookmark{
+		{
+			URL:         "https://foo.io/",
+			Title:       "Foo",
+			AddDate:     time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+			Folder:      []string{"Dev", "Go"},
+			Tags:        []string{"go", "dev"},
+			Description: "my note",
+		},
+		{
+			URL:         "https://bar.io/",
+			Title:       "Bar",
+			AddDate:     time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC),
+			Folder:      []string{"Unsorted"},
+			Description: "bar excerpt",
+		},
+		{URL: "https://short.io/", Title: "Short row"},
+	}, bs)
+
+	bs[0].Status, bs[1].Status, bs[2].Status = Dead, Alive, Unknown
+	out := &bytes.Buffer{}
+	assert.Nil(t, w.WriteCleaned(out))
+	assert.Equal(t, "\ufeffid,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite\n"+
+		"102,Bar,,bar excerpt,https://bar.io/,Unsorted,,2021-03-04T05:06:08.000Z,,,false\n", out.String())
+}
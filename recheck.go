@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+var errCarried = errors.New("carried from previous result")
+
+// RecheckPinger re-checks URLs whose previous status is among the Recheck ones, and carries the previous results of
+// all the other URLs through without pinging them. URLs without previous result are always checked.
+type RecheckPinger struct {
+	Prior   map[string]Record // keyed by canonical URL
+	Canon   CanonOptions      // options Prior is canonicalized with
+	Recheck map[PingStatus]bool
+	Pinger  Pinger
+}
+
+// Ping pings url with p.Pinger if it needs a recheck, otherwise it returns the previous result of url.
+func (p *RecheckPinger) Ping(ctx context.Context, url string) (PingStatus, int, error) {
+	rec, ok := p.Prior[CanonicalURL(url, p.Canon)]
+	if !ok || p.Recheck[rec.Status] {
+		return p.Pinger.Ping(ctx, url)
+	}
+	var err error
+	if rec.Reason != "" {
+		err = &PingError{Reason: rec.Reason, Err: errCarried}
+	}
+	return rec.Status, rec.Code, err
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRecheckPinger(t *testing.T) {
+	pmock := &pingerMock{}
+	pmock.On("Ping", "https://bar.io").Return(Alive, http.StatusOK, nil).Once()
+	pmock.On("Ping", "https://new.io").Return(Dead, http.StatusNotFound, nil).Once()
+	p := &RecheckPinger{
+		Prior: map[string]Record{
+			CanonicalURL("https://foo.io", CanonOptions{}): {Status: Dead, Code: http.StatusGone, Reason: ReasonHTTP4xx},
+			CanonicalURL("https://bar.io", CanonOptions{}): {Status: Unknown, Code: http.StatusTooManyRequests, Reason: ReasonHTTP4xx},
+			CanonicalURL("https://qux.io", CanonOptions{}): {Status: Alive, Code: http.StatusOK},
+		},
+		Recheck: map[PingStatus]bool{Unknown: true},
+		Pinger:  pmock,
+	}
+	status, code, err := p.Ping(context.Background(), "https://foo.io/")
+	assert.Equal(t, Dead, status)
+	assert.Equal(t, http.StatusGone, code)
+	assert.Equal(t, ReasonHTTP4xx, reasonOf(err))
+	status, code, err = p.Ping(context.Background(), "https://qux.io")
+	assert.Equal(t, Alive, status)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, err)
+	status, code, err = p.Ping(context.Background(), "https://bar.io")
+	assert.Equal(t, Alive, status)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, err)
+	status, _, _ = p.Ping(context.Background(), "https://new.io")
+	assert.Equal(t, Dead, status)
+	pmock.AssertExpectations(t)
+}
+
+func TestStartWashTillDone_Recheck(t *testing.T) {
+	in := `# washed last week
+alive	https://foo.io	200	-	FooDir	about foo
+dead	https://bar.io	410	http_4xx	FooDir	-
+unknown	https://qux.io	429	http_4xx	-	-
+`
+	dmock := &doerMock{}
+	dmock.On("Do", mock.Anything).Return(genResp(http.StatusOK), nil).Once()
+	out := &bytes.Buffer{}
+	StartWashTillDone(context.Background(), strings.NewReader(in), out, dmock, 2, genTstLogger(), WithRecheck(Unknown, Dead),
+		WithSkipNetwork(nil))
+	// nothing is pinged over network with -s, so rechecked URLs end up unknown
+	assert.Contains(t, out.String(), "alive\thttps://foo.io\t200\t-\tFooDir\tabout foo\n")
+	assert.Contains(t, out.String(), "unknown\thttps://bar.io\t-\tskipped\tFooDir\t-\n")
+	assert.Contains(t, out.String(), "unknown\thttps://qux.io\t-\tskipped\t-\t-\n")
+
+	out.Reset()
+	StartWashTillDone(context.Background(), strings.NewReader(in), out, dmock, 2, genTstLogger(), WithRecheck(Unknown))
+	dmock.AssertExpectations(t)
+	assert.Contains(t, out.String(), "alive\thttps://foo.io\t200\t-\tFooDir\tabout foo\n")
+	assert.Contains(t, out.String(), "dead\thttps://bar.io\t410\thttp_4xx\tFooDir\t-\n")
+	assert.Contains(t, out.String(), "alive\thttps://qux.io\t200\t-\t-\t-\n")
+}
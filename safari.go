@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"time"
+
+	"go.uber.org/zap"
+	"howett.net/plist"
+)
+
+const (
+	safariTypeList    = "WebBookmarkTypeList"
+	safariTypeLeaf    = "WebBookmarkTypeLeaf"
+	safariReadingList = "com.apple.ReadingList"
+)
+
+// titles of Safari top level folders, the same as what Safari displays
+var safariRootTitles = map[string]string{
+	"BookmarksBar":    "Favorites",
+	"BookmarksMenu":   "Bookmarks Menu",
+	safariReadingList: "Reading List",
+}
+
+// SafariWalker walks the Bookmarks.plist file of Safari, in either binary or XML property list format.
+type SafariWalker struct {
+	chanWalker
+	r io.Reader
+}
+
+// safariNode is a bookmark or folder in Bookmarks.plist.
+type safariNode struct {
+	Type          string `plist:"WebBookmarkType"`
+	Title         string `plist:"Title"`
+	URL           string `plist:"URLString"`
+	URIDictionary struct {
+		Title string `plist:"title"`
+	} `plist:"URIDictionary"`
+	ReadingList struct {
+		DateAdded   time.Time `plist:"DateAdded"`
+		PreviewText string    `plist:"PreviewText"`
+	} `plist:"ReadingList"`
+	Children []*safariNode `plist:"Children"`
+}
+
+func NewSafariWalker(r io.Reader, log *zap.SugaredLogger) *SafariWalker {
+	w := &SafariWalker{r: r}
+	w.chanWalker = newChanWalker(w.walk, log)
+	return w
+}
+
+func (w *SafariWalker) walk() {
+	defer close(w.wchan)
+	// plist decoder sniffs the encoding, which requires random access to the whole input
+	b, err := ioutil.ReadAll(w.r)
+	if err != nil {
+		w.send(&Result{nil, err})
+		return
+	}
+	root := &safariNode{}
+	if _, err := plist.Unmarshal(b, root); err != nil {
+		w.send(&Result{nil, err})
+		return
+	}
+	for _, n := range root.Children {
+		if !w.walkNode(n, nil) {
+			return
+		}
+	}
+}
+
+// walkNode sends n as bookmark if it is a leaf in Bookmarks.plist, or walks its children if it is a list, titling
+// top-level lists like Safari does. folder holds the titles of the lists enclosing n. It returns false if walker
+// is stopped.
+func (w *SafariWalker) walkNode(n *safariNode, folder []string) bool {
+	switch n.Type {
+	case safariTypeLeaf:
+		bmk := &Bookmark{
+			URL:         n.URL,
+			Title:       n.URIDictionary.Title,
+			AddDate:     n.ReadingList.DateAdded,
+			Folder:      folder,
+			Description: n.ReadingList.PreviewText,
+		}
+		w.log.Infow("created bookmark", "bookmark", bmk)
+		return w.send(&Result{bmk, nil})
+	case safariTypeList:
+		title := n.Title
+		if t, ok := safariRootTitles[title]; ok && len(folder) == 0 {
+			title = t
+		}
+		path := make([]string, len(folder), len(folder)+1)
+		copy(path, folder)
+		path = append(path, title)
+		for _, c := range n.Children {
+			if !w.walkNode(c, path) {
+				return false
+			}
+		}
+	}
+	// other types, e.g. WebBookmarkTypeProxy for History, hold no bookmark
+	return true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafariWalker(t *testing.T) {
+	exp := []*Bookmark{
+		{
+			URL:    "https://bar.io/",
+			Title:  "Bar",
+			Folder: []string{"Favorites"},
+		},
+		{
+			URL:    "https://qux.io/",
+			Title:  "Qux & Co",
+			Folder: []string{"Favorites", "BarDir"},
+		},
+		{
+			URL:     "https://bee.io/post",
+			Title:   "Bee Post",
+			AddDate: time.Date(2020, 7, 18, 8, 30, 0, 0, time.UTC),
+			Folder:  []string{"Reading List"},
+			// preview text of reading list item
+			Description: "All about bees",
+		},
+	}
+	for _, fixture := range []string{"testdata/safari/Bookmarks.xml.plist", "testdata/safari/Bookmarks.binary.plist"} {
+		t.Run(fixture, func(t *testing.T) {
+			f, err := os.Open(fixture)
+			require.Nil(t, err)
+			defer f.Close()
+			w := NewSafariWalker(f, genTstLogger())
+			bs := []*Bookmark{}
+			for {
+				b, err := w.Next(context.Background())
+				if err == io.EOF {
+					break
+				}
+				assert.Nil(t, err)
+				bs = append(bs, b)
+			}
+			assert.Equal(t, exp, bs)
+		})
+	}
+}
+
+func TestSafariWalker_Malformed(t *testing.T) {
+	w := NewSafariWalker(strings.NewReader("bplist00 junk"), genTstLogger())
+	b, err := w.Next(context.Background())
+	assert.Nil(t, b)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, io.EOF, err)
+	_, err = w.Next(context.Background())
+	assert.Equal(t, io.EOF, err)
+}
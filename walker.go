@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -11,32 +15,85 @@ import (
 
 const (
 	anchorTag = "a"
+	h3Tag     = "h3"
+	dlTag     = "dl"
+	dtTag     = "dt"
+	ddTag     = "dd"
+	titleTag  = "title"
 )
 
 type Bookmark struct {
-	URL     string
-	Title   string
-	AddDate time.Time
-	Status  PingStatus
-	// TODO: we may want other attributes in the future
+	URL          string
+	Title        string
+	AddDate      time.Time
+	LastModified time.Time
+	Status       PingStatus
+	// Code is the final response status code of pinging URL, if any.
+	Code int
+	// Folder is the path of folders holding the bookmark, from the outermost one.
+	Folder []string
+	// Tags are the tags user labels the bookmark with.
+	Tags []string
+	// Keyword is the shortcut user can type into address bar to visit the bookmark.
+	Keyword string
+	// Icon is the favicon of bookmarked page, usually in form of data URL.
+	Icon string
+	// IconURI is where Icon is fetched from.
+	IconURI string
+	// Description is the note user attaches to the bookmark.
+	Description string
+	// Private tells whether the bookmark is hidden from others, as in bookmarking services like Pinboard.
+	Private bool
+	// Attr holds all the raw attributes of the bookmark, in the order they appear in input.
+	Attr []html.Attribute
+	// Warnings are problems walker tolerated when parsing the bookmark.
+	Warnings []string
+	// Source is the name of input which the bookmark comes from, when there are multiple ones.
+	Source string
 }
 
-// Walker walks through input text stream of bookmarks, assuming the input text stream is already utf-8 encoded HTML.
+// Attribute returns value of the raw attribute whose key is key, case-insensitively. It returns false if there is
+// no such attribute.
+func (b *Bookmark) Attribute(key string) (string, bool) {
+	for _, a := range b.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// Node is a node of the bookmark tree walked by NetscapeWalker. A node is either a folder, or a leaf holding
+// a bookmark.
+type Node struct {
+	Title       string
+	Description string
+	Attr        []html.Attribute // raw attributes of folder header
+	Bookmark    *Bookmark        // non-nil if node is a leaf
+	Children    []*Node          // children of folder, in the order they appear in input
+}
+
+// Walker walks through input stream of bookmarks. Each implementation walks bookmarks in a specific format.
 type Walker interface {
 	// Next walks to and returns the next bookmark. It returns io.EOF when walking to the end of text
-	// stream, or a non-EOF error when error happened during walk.
-	Next() (*Bookmark, error)
+	// stream, ctx.Err() if ctx is done before the next bookmark is walked to, or a non-EOF error when error
+	// happened during walk.
+	Next(ctx context.Context) (*Bookmark, error)
 	// Stop stops and exits the walk. Consecutive calls to Next() after calling Stop() *eventually* returns io.EOF
 	Stop()
 }
 
-// NetscapeWalker walks text stream in Netscape Bookmark File Format.
-type NetscapeWalker struct {
-	tokenizer *html.Tokenizer
-	wchan     chan *Result
-	done      chan struct{}
-	log       *zap.SugaredLogger
-	started   bool
+// exhaust stops walker and waits till it exits, i.e. Next returns io.EOF, so that what walker keeps about the
+// walked bookmarks is no longer changing. It returns ctx.Err() if ctx is done before then.
+func exhaust(ctx context.Context, walker Walker) error {
+	walker.Stop()
+	for {
+		if _, err := walker.Next(ctx); err == io.EOF {
+			return nil
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
 }
 
 type Result struct {
@@ -44,103 +101,307 @@ type Result struct {
 	E error
 }
 
-func NewNetscapeWalker(r io.Reader, log *zap.SugaredLogger) *NetscapeWalker {
-	// keep constructor simple, avoid heavy operations like spinning up goroutines etc
-	t := html.NewTokenizer(r)
-	w := &NetscapeWalker{}
-	w.tokenizer = t
-	w.wchan = make(chan *Result)
-	w.done = make(chan struct{})
-	w.log = log
-	return w
+// chanWalker implements Walker by vending the results walk function sends over wchan. walk runs in its own
+// goroutine once Next() is called, and it should close wchan on exit.
+type chanWalker struct {
+	walk    func()
+	wchan   chan *Result
+	done    chan struct{}
+	stop    *sync.Once
+	log     *zap.SugaredLogger
+	started bool
+}
+
+func newChanWalker(walk func(), log *zap.SugaredLogger) chanWalker {
+	return chanWalker{walk: walk, wchan: make(chan *Result), done: make(chan struct{}), stop: &sync.Once{}, log: log}
 }
 
-func (w *NetscapeWalker) Next() (*Bookmark, error) {
+func (w *chanWalker) Next(ctx context.Context) (*Bookmark, error) {
 	// no need to be goroutine-safe for now
 	if !w.started {
 		w.log.Debug("start walking")
 		go w.walk()
 		w.started = true
 	}
-	r, ok := <-w.wchan
-	if !ok {
-		return nil, io.EOF
+	select {
+	case r, ok := <-w.wchan:
+		if !ok {
+			return nil, io.EOF
+		}
+		return r.B, r.E
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	return r.B, r.E
 }
 
-func (w *NetscapeWalker) Stop() {
-	close(w.done)
+func (w *chanWalker) Stop() {
+	w.stop.Do(func() { close(w.done) })
+}
+
+// send sends r over wchan. It returns false if the walker is stopped before r is sent.
+func (w *chanWalker) send(r *Result) bool {
+	select {
+	case w.wchan <- r:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// NetscapeWalker walks text stream in Netscape Bookmark File Format.
+type NetscapeWalker struct {
+	chanWalker
+	// Strict makes walker abort the walk on any malformed bookmark. Otherwise walker tolerates the problems and
+	// records them as warnings of the bookmark.
+	Strict    bool
+	tokenizer *html.Tokenizer
+	root      *Node
+}
+
+func NewNetscapeWalker(r io.Reader, log *zap.SugaredLogger) *NetscapeWalker {
+	// keep constructor simple, avoid heavy operations like spinning up goroutines etc
+	t := html.NewTokenizer(r)
+	w := &NetscapeWalker{}
+	w.chanWalker = newChanWalker(w.walk, log)
+	w.tokenizer = t
+	w.root = &Node{}
+	return w
+}
+
+// Root returns the root of bookmark tree walked so far. The tree is complete only after the walker is exhausted.
+func (w *NetscapeWalker) Root() *Node {
+	return w.root
 }
 
 func (w *NetscapeWalker) walk() {
 	log := w.log
-	wchan := w.wchan
-	defer close(wchan)
+	defer close(w.wchan)
 	z := w.tokenizer
 	var bmk *Bookmark
+	// walked is the bookmark whose anchor is walked but is yet to dispatch, as its description(<DD>) may follow
+	var walked *Bookmark
+	dispatch := func() bool {
+		if walked == nil {
+			return true
+		}
+		b := walked
+		walked = nil
+		b.Description = strings.TrimSpace(b.Description)
+		// log before sending, as the receiver owns b afterwards
+		log.Infow("dispatched bookmark", "bookmark", b)
+		return w.send(&Result{b, nil})
+	}
+	// folders tracks the folder nesting via <DL> lists. folder is the folder whose header(<H3>) is just walked
+	// and its <DL> list is yet to come.
+	folders, folder := []*Node{w.root}, (*Node)(nil)
+	// text receives the text of element being walked, if any. desc receives text of description being walked.
+	var text, desc *string
 	for {
 		switch tt := z.Next(); tt {
 		case html.ErrorToken:
-			select {
-			case wchan <- &Result{nil, z.Err()}:
-			case <-w.done:
+			if dispatch() {
+				w.send(&Result{nil, z.Err()})
 			}
 			return
 		case html.StartTagToken:
 			t := z.Token()
+			switch t.Data {
+			case dtTag, dlTag, h3Tag, anchorTag, ddTag:
+				// <DD> ends when next element starts
+				if desc != nil {
+					*desc = strings.TrimSpace(*desc)
+					desc = nil
+				}
+				if t.Data != ddTag && !dispatch() {
+					return
+				}
+			}
+			switch t.Data {
+			case titleTag:
+				text = &w.root.Title
+				continue
+			case h3Tag:
+				folder = &Node{Attr: t.Attr}
+				parent := folders[len(folders)-1]
+				parent.Children = append(parent.Children, folder)
+				text = &folder.Title
+				continue
+			case ddTag:
+				if walked != nil {
+					desc = &walked.Description
+				} else if folder != nil {
+					desc = &folder.Description
+				}
+				continue
+			case dlTag:
+				if folder != nil {
+					folders = append(folders, folder)
+				} else {
+					// keep <DL> and </DL> paired up
+					folders = append(folders, folders[len(folders)-1])
+				}
+				folder = nil
+				continue
+			}
 			if t.Data != anchorTag || len(t.Attr) == 0 {
 				continue
 			}
 			log.Debugw("get anchor start tag with attributes", "data", t.Data, "attr", t.Attr)
 			var err error
-			bmk, err = genBookmark(t.Attr)
+			bmk, err = genBookmark(t.Attr, w.Strict)
 			log.Infow("created bookmark", "bookmark", bmk, "err", err)
+			if err == nil && len(bmk.Warnings) > 0 {
+				log.Warnw("tolerated malformed bookmark", "url", bmk.URL, "warnings", bmk.Warnings)
+			}
 			if err != nil {
-				select {
-				case wchan <- &Result{nil, err}:
-				case <-w.done:
-				}
+				w.send(&Result{nil, err})
 				return
 			}
+			if bmk.URL == "" {
+				// nothing to wash, title is still read to be logged
+				continue
+			}
+			bmk.Folder = folderPath(folders)
+			parent := folders[len(folders)-1]
+			parent.Children = append(parent.Children, &Node{Bookmark: bmk})
 		case html.EndTagToken:
 			t := z.Token()
+			switch t.Data {
+			case titleTag, h3Tag:
+				if text != nil {
+					*text = collapseSpace(*text)
+				}
+				text = nil
+				continue
+			case dlTag:
+				if desc != nil {
+					*desc = strings.TrimSpace(*desc)
+					desc = nil
+				}
+				if !dispatch() {
+					return
+				}
+				if len(folders) > 1 {
+					folders = folders[:len(folders)-1]
+				}
+				continue
+			}
 			log.Debugw("get anchor end tag", "containsBookmark", bmk != nil)
 			if t.Data != anchorTag || bmk == nil {
 				continue
 			}
-			select {
-			case wchan <- &Result{bmk, nil}:
-				log.Infow("dispatched bookmark", "bookmark", bmk)
-			case <-w.done:
-				return
+			bmk.Title = collapseSpace(bmk.Title)
+			if bmk.URL == "" {
+				log.Warnw("skipped bookmark without URL", "title", bmk.Title)
+				bmk = nil
+				continue
 			}
+			log.Debugw("assigned bookmark title", "bookmark", bmk)
+			walked = bmk
 			// reset
 			bmk = nil
 		case html.TextToken:
-			// assume bookmark title text has been url-escaped
+			// title may come in multiple text tokens, e.g. when broken up by nested tags like <b>. Token unescapes
+			// entities in text.
 			if bmk != nil {
-				bmk.Title = z.Token().Data
-				log.Debugw("assgined bookmark title", "bookmark", bmk)
+				bmk.Title += z.Token().Data
+			} else if desc != nil {
+				*desc += z.Token().Data
+			} else if text != nil {
+				*text += z.Token().Data
 			}
 		}
 	}
 }
 
-func genBookmark(attr []html.Attribute) (*Bookmark, error) {
-	var url string
-	var addDate time.Time
+// folderPath returns the titles of given folder stack, skipping the root one.
+func folderPath(folders []*Node) []string {
+	var path []string
+	for i := 1; i < len(folders); i++ {
+		// a folder appears multiple times in stack if its <DL> list is directly nested in another one
+		if folders[i] != folders[i-1] {
+			path = append(path, folders[i].Title)
+		}
+	}
+	return path
+}
+
+// collapseSpace trims s and collapses each run of whitespaces in it into a single space.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// genBookmark creates bookmark from anchor attributes. In non-strict mode, it records malformed attributes as
+// warnings instead of returning error.
+func genBookmark(attr []html.Attribute, strict bool) (*Bookmark, error) {
+	bmk := &Bookmark{Attr: attr}
+	parseTime := parseUnixSeconds
+	if !strict {
+		parseTime = func(s string) (time.Time, error) { return parseTimestamp(s, bmk) }
+	}
 	for _, a := range attr {
+		var err error
 		switch a.Key {
 		case "href":
-			url = a.Val
+			bmk.URL = a.Val
 		case "add_date":
-			addDateSeconds, err := strconv.ParseInt(a.Val, 10, 64)
-			if err != nil {
-				return nil, err
-			}
-			addDate = time.Unix(addDateSeconds, 0)
+			bmk.AddDate, err = parseTime(a.Val)
+		case "last_modified":
+			bmk.LastModified, err = parseTime(a.Val)
+		case "tags":
+			bmk.Tags = splitTags(a.Val, ",")
+		case "shortcuturl":
+			bmk.Keyword = a.Val
+		case "icon":
+			bmk.Icon = a.Val
+		case "icon_uri":
+			bmk.IconURI = a.Val
+		case "private":
+			bmk.Private = a.Val == "1"
+		}
+		if err != nil && strict {
+			return nil, err
+		} else if err != nil {
+			bmk.Warnings = append(bmk.Warnings, fmt.Sprintf("invalid %s %q: %s", a.Key, a.Val, err))
+		}
+	}
+	return bmk, nil
+}
+
+// parseTimestamp parses s as seconds since Unix epoch, as parseUnixSeconds does, but also accepts timestamps in
+// milliseconds or microseconds which some exporters produce. Such tolerance is recorded as warning of bmk.
+func parseTimestamp(s string, bmk *Bookmark) (time.Time, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// seconds this large are way beyond year 5000
+	switch {
+	case n > 1e14:
+		bmk.Warnings = append(bmk.Warnings, fmt.Sprintf("timestamp %s taken as microseconds", s))
+		return time.Unix(0, n*int64(time.Microsecond)), nil
+	case n > 1e11:
+		bmk.Warnings = append(bmk.Warnings, fmt.Sprintf("timestamp %s taken as milliseconds", s))
+		return time.Unix(0, n*int64(time.Millisecond)), nil
+	}
+	return time.Unix(n, 0), nil
+}
+
+func parseUnixSeconds(s string) (time.Time, error) {
+	seconds, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// splitTags splits tags separated by sep.
+func splitTags(s, sep string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, sep) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
 		}
 	}
-	return &Bookmark{URL: url, AddDate: addDate}, nil
+	return tags
 }
@@ -2,17 +2,23 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
 )
 
 func TestNetscapeWalker(t *testing.T) {
 	tcs := []struct {
 		name         string
 		reader       io.Reader
+		strict       bool
 		expBookmarks []*Bookmark
 		expErrs      []bool // expect a non-nil error or not
 	}{
@@ -41,16 +47,25 @@ func TestNetscapeWalker(t *testing.T) {
 					URL:     "https://bar.io/",
 					Title:   "Bar",
 					AddDate: time.Unix(1515361177, 0),
+					Attr:    genTstAttr("https://bar.io/", "1515361177", "data:image/png;base64,blah=="),
+					Icon:    "data:image/png;base64,blah==",
+					Folder:  []string{"FooDir"},
 				},
 				{
 					URL:     "https://qux.io/",
 					Title:   "Qux",
 					AddDate: time.Unix(1515361177, 0),
+					Attr:    genTstAttr("https://qux.io/", "1515361177", "data:image/png;base64,blah=="),
+					Icon:    "data:image/png;base64,blah==",
+					Folder:  []string{"FooDir", "BarDir"},
 				},
 				{
 					URL:     "https://bee.io/",
 					Title:   "Bee",
 					AddDate: time.Unix(1515361173, 0),
+					Attr:    genTstAttr("https://bee.io/", "1515361173", "data:image/png;base64,blahblah=="),
+					Icon:    "data:image/png;base64,blahblah==",
+					Folder:  []string{"FooDir", "BarDir", "Qux Dir"},
 				},
 			},
 			expErrs: []bool{false, false, false},
@@ -69,7 +84,106 @@ func TestNetscapeWalker(t *testing.T) {
 			expErrs:      []bool{},
 		},
 		{
-			name: "MalformedBookmarkFile",
+			name: "AllAttributes",
+			reader: bytes.NewReader([]byte(`<DL><p>
+    <DT><A HREF="https://bar.io/" ADD_DATE="1515361177" LAST_MODIFIED="1515361178" ICON_URI="https://bar.io/favicon.ico" ICON="data:image/png;base64,blah==" SHORTCUTURL="b" TAGS="dev, fun,," PRIVATE="1" DATA-FOO="foo">Bar</A>
+</DL><p>
+`)),
+			expBookmarks: []*Bookmark{
+				{
+					URL:          "https://bar.io/",
+					Title:        "Bar",
+					AddDate:      time.Unix(1515361177, 0),
+					LastModified: time.Unix(1515361178, 0),
+					Tags:         []string{"dev", "fun"},
+					Keyword:      "b",
+					Icon:         "data:image/png;base64,blah==",
+					IconURI:      "https://bar.io/favicon.ico",
+					Private:      true,
+					Attr: []html.Attribute{
+						{Key: "href", Val: "https://bar.io/"},
+						{Key: "add_date", Val: "1515361177"},
+						{Key: "last_modified", Val: "1515361178"},
+						{Key: "icon_uri", Val: "https://bar.io/favicon.ico"},
+						{Key: "icon", Val: "data:image/png;base64,blah=="},
+						{Key: "shortcuturl", Val: "b"},
+						{Key: "tags", Val: "dev, fun,,"},
+						{Key: "private", Val: "1"},
+						{Key: "data-foo", Val: "foo"},
+					},
+				},
+			},
+			expErrs: []bool{false},
+		},
+		{
+			name: "Description",
+			reader: bytes.NewReader([]byte(`<DL><p>
+    <DT><H3>FooDir</H3>
+    <DD>all about foo
+    <DL><p>
+        <DT><A HREF="https://bar.io/">Bar</A>
+        <DD>Bar &amp; its
+            friends
+        <DT><A HREF="https://qux.io/">Qux</A>
+        <DT><A HREF="https://bee.io/">Bee</A>
+        <DD>last one
+    </DL><p>
+</DL><p>
+`)),
+			expBookmarks: []*Bookmark{
+				{
+					URL:         "https://bar.io/",
+					Title:       "Bar",
+					Attr:        []html.Attribute{{Key: "href", Val: "https://bar.io/"}},
+					Folder:      []string{"FooDir"},
+					Description: "Bar & its\n            friends",
+				},
+				{
+					URL:    "https://qux.io/",
+					Title:  "Qux",
+					Attr:   []html.Attribute{{Key: "href", Val: "https://qux.io/"}},
+					Folder: []string{"FooDir"},
+				},
+				{
+					URL:         "https://bee.io/",
+					Title:       "Bee",
+					Attr:        []html.Attribute{{Key: "href", Val: "https://bee.io/"}},
+					Folder:      []string{"FooDir"},
+					Description: "last one",
+				},
+			},
+			expErrs: []bool{false, false, false},
+		},
+		{
+			name: "DirectlyNestedList",
+			reader: bytes.NewReader([]byte(`<DL><p>
+    <DT><H3>FooDir</H3>
+    <DL><p>
+        <DL><p>
+            <DT><A HREF="https://bar.io/">Bar</A>
+        </DL><p>
+    </DL><p>
+    <DT><A HREF="https://qux.io/">Qux</A>
+</DL><p>
+`)),
+			expBookmarks: []*Bookmark{
+				{
+					URL:    "https://bar.io/",
+					Title:  "Bar",
+					Attr:   []html.Attribute{{Key: "href", Val: "https://bar.io/"}},
+					Folder: []string{"FooDir"},
+				},
+				{
+					URL:   "https://qux.io/",
+					Title: "Qux",
+					Attr:  []html.Attribute{{Key: "href", Val: "https://qux.io/"}},
+				},
+			},
+			expErrs: []bool{false, false},
+		},
+		{
+			name:   "MalformedBookmarkFile",
+			strict: true,
 			reader: bytes.NewReader([]byte(`<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <!-- This is an automatically generated file.
      It will be read and overwritten.
@@ -93,11 +207,47 @@ func TestNetscapeWalker(t *testing.T) {
 					URL:     "https://bar.io/",
 					Title:   "Bar",
 					AddDate: time.Unix(1515361177, 0),
+					Attr:    genTstAttr("https://bar.io/", "1515361177", "data:image/png;base64,blah=="),
+					Icon:    "data:image/png;base64,blah==",
+					Folder:  []string{"FooDir"},
 				},
 				(*Bookmark)(nil),
 			},
 			expErrs: []bool{false, true},
 		},
+		{
+			name: "MalformedBookmarkLenient",
+			reader: bytes.NewReader([]byte(`<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1515126229" LAST_MODIFIED="1592885828">BarDir</H3>
+    <DL><p>
+        <DT><A HREF="https://qux.io/" ADD_DATE="junkie">Qux</A>
+        <DT><A HREF="https://bee.io/" ADD_DATE="1515361173123">Bee</A>
+        <DT><A ADD_DATE="1515361173">Nowhere</A>
+    </DL><p>
+</DL><p>
+`)),
+			expBookmarks: []*Bookmark{
+				{
+					URL:      "https://qux.io/",
+					Title:    "Qux",
+					Attr:     []html.Attribute{{Key: "href", Val: "https://qux.io/"}, {Key: "add_date", Val: "junkie"}},
+					Folder:   []string{"BarDir"},
+					Warnings: []string{`invalid add_date "junkie": strconv.ParseInt: parsing "junkie": invalid syntax`},
+				},
+				{
+					URL:      "https://bee.io/",
+					Title:    "Bee",
+					AddDate:  time.Unix(1515361173, 123000000),
+					Attr:     []html.Attribute{{Key: "href", Val: "https://bee.io/"}, {Key: "add_date", Val: "1515361173123"}},
+					Folder:   []string{"BarDir"},
+					Warnings: []string{"timestamp 1515361173123 taken as milliseconds"},
+				},
+			},
+			expErrs: []bool{false, false},
+		},
 	}
 	log := genTstLogger()
 	for _, cs := range tcs {
@@ -105,9 +255,10 @@ func TestNetscapeWalker(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
 			w := NewNetscapeWalker(c.reader, log)
+			w.Strict = c.strict
 			bs, errs := make([]*Bookmark, 0, len(c.expBookmarks)), make([]bool, 0, len(c.expErrs))
 			for {
-				b, err := w.Next()
+				b, err := w.Next(context.Background())
 				if err != nil && err == io.EOF {
 					break
 				}
@@ -131,7 +282,7 @@ func TestWalkerStopEarly(t *testing.T) {
 	go func() {
 		defer close(done)
 		for {
-			b, err := walker.Next()
+			b, err := walker.Next(context.Background())
 			if !started {
 				started = true
 				close(start)
@@ -157,6 +308,19 @@ func TestWalkerStopEarly(t *testing.T) {
 	}
 }
 
+func TestWalkerNextContextDone(t *testing.T) {
+	// walker blocks on reading input which never comes
+	r, w := io.Pipe()
+	defer w.Close()
+	walker := NewNetscapeWalker(r, genTstLogger())
+	defer walker.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b, err := walker.Next(ctx)
+	assert.Nil(t, b)
+	assert.Equal(t, context.Canceled, err)
+}
+
 // infReader mimics reading an inifinite byte stream of bookmarks.
 type infReader struct {
 	curr io.Reader
@@ -179,3 +343,100 @@ func (r *infReader) Read(p []byte) (int, error) {
 func (r *infReader) spare() io.Reader {
 	return bytes.NewReader([]byte(`<DT><A HREF="https://foo/" ADD_DATE="1515361177" ICON="data:image/png;base64,blah==">Foo</A>`))
 }
+
+func genTstAttr(href, addDate, icon string) []html.Attribute {
+	return []html.Attribute{{Key: "href", Val: href}, {Key: "add_date", Val: addDate}, {Key: "icon", Val: icon}}
+}
+
+func TestBookmark_Attribute(t *testing.T) {
+	b := &Bookmark{Attr: []html.Attribute{{Key: "href", Val: "https://foo.io"}, {Key: "tags", Val: ""}}}
+	v, ok := b.Attribute("HREF")
+	assert.True(t, ok)
+	assert.Equal(t, "https://foo.io", v)
+	v, ok = b.Attribute("tags")
+	assert.True(t, ok)
+	assert.Empty(t, v)
+	_, ok = b.Attribute("icon")
+	assert.False(t, ok)
+}
+
+func TestNetscapeWalker_Titles(t *testing.T) {
+	type entry struct {
+		url, title, folder string
+	}
+	tcs := []struct {
+		name     string
+		file     string
+		expTitle string // title of the bookmark file
+		exp      []entry
+	}{
+		{
+			name:     "Chrome",
+			file:     "testdata/netscape/chrome.html",
+			expTitle: "Bookmarks",
+			exp: []entry{
+				{"https://go.dev/doc/effective_go", "Effective Go - The Go Programming Language", "Bookmarks bar"},
+				{"https://www.att.com/", "AT&T Official Site | Unlimited Data Plans, Internet Service, & TV", "Bookmarks bar"},
+				{"https://doc.rust-lang.org/book/", "The Rust Programming Language — \"the book\"", "Bookmarks bar/Rust & Go"},
+				{"https://blog.golang.org/why-generics", "Why Generics? – The Go Blog", "Bookmarks bar/Rust & Go"},
+				{"https://en.wikipedia.org/wiki/Caf%C3%A9", "Café - Wikipedia", "Other bookmarks"},
+				{"https://stackoverflow.com/q/1732348", "RegEx match open tags except XHTML self-contained tags <a> <br />", "Other bookmarks"},
+			},
+		},
+		{
+			name:     "Firefox",
+			file:     "testdata/netscape/firefox.html",
+			expTitle: "Bookmarks",
+			exp: []entry{
+				{"https://support.mozilla.org/en-US/products/firefox", "Get Help", ""},
+				{"https://www.mozilla.org/en-US/about/", "About Us – Mozilla's mission", ""},
+				{"https://developer.mozilla.org/en-US/docs/Web/HTML/Element/a", "<a>: The Anchor element - HTML: HyperText Markup Language | MDN", "Bookmarks Toolbar"},
+				{"https://news.ycombinator.com/", "Hacker News", "Bookmarks Toolbar"},
+				{"https://www.rfc-editor.org/rfc/rfc3986", "RFC 3986: Uniform Resource Identifier (URI): Generic Syntax", "Other Bookmarks"},
+			},
+		},
+		{
+			name:     "Edge",
+			file:     "testdata/netscape/edge.html",
+			expTitle: "Bookmarks",
+			exp: []entry{
+				{"https://www.microsoft.com/en-us/edge", "Microsoft Edge | Browser & Web Features", "Favorites bar"},
+				{"https://docs.microsoft.com/en-us/windows/", "Windows documentation - Microsoft Docs", "Favorites bar"},
+				{"http://go.microsoft.com/fwlink/p/?LinkId=255142", "Websites for United States on Microsoft® Bing", "Favorites bar/Imported from IE"},
+			},
+		},
+		{
+			name:     "Pocket",
+			file:     "testdata/netscape/pocket.html",
+			expTitle: "Pocket Export",
+			exp: []entry{
+				{"https://www.theatlantic.com/magazine/archive/1945/07/as-we-may-think/303881/", "As We May Think - The Atlantic", ""},
+				{"https://martinfowler.com/articles/microservices.html", "Microservices – a definition of this new architectural term", ""},
+				{"https://www.paulgraham.com/avg.html", "Beating the Averages", ""},
+				{"https://www.joelonsoftware.com/2000/08/09/the-joel-test-12-steps-to-better-code/", "The Joel Test: 12 Steps to Better Code – Joel on Software", ""},
+			},
+		},
+	}
+	log := genTstLogger()
+	for _, cs := range tcs {
+		c := cs
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			f, err := os.Open(c.file)
+			require.Nil(t, err)
+			defer f.Close()
+			w := NewNetscapeWalker(f, log)
+			got := []entry{}
+			for {
+				b, err := w.Next(context.Background())
+				if err == io.EOF {
+					break
+				}
+				require.Nil(t, err)
+				got = append(got, entry{b.URL, b.Title, strings.Join(b.Folder, "/")})
+			}
+			assert.Equal(t, c.exp, got)
+			assert.Equal(t, c.expTitle, w.Root().Title)
+		})
+	}
+}
@@ -1,43 +1,271 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 type Washer struct {
+	// Canon customizes the canonicalization of URLs. Bookmarks of the same canonical URL are pinged only once.
+	Canon CanonOptions
+	// HostLimit limits the concurrent pings of each host, if positive. Like cquota, it only applies to pings over
+	// the network, i.e. by HTTPinger.
+	HostLimit int
+	// HostDelay is the min delay between the starts of pings to the same host over the network.
+	HostDelay time.Duration
+
 	walker  Walker
 	pinger  Pinger
 	log     *zap.SugaredLogger
 	walked  chan *Bookmark // vends bookmarks from walker for washing
 	washed  chan *Result   // vends washed bookmarks
 	cquota  chan struct{}  // limits concurrency
-	done    chan struct{}  // signals termination
+	ctx     context.Context
+	cancel  context.CancelFunc // signals termination, by cancelling ctx of walking and pinging
+	wctx    context.Context    // ctx of walking, derived from ctx
+	drain   context.CancelFunc // stops walking only, by cancelling wctx
 	started bool
+	mu      sync.Mutex
+	groups  map[string]*urlGroup // bookmarks walked so far, keyed by canonical URL
+}
+
+// urlGroup is the group of bookmarks sharing the same canonical URL, which share the ping result as well.
+type urlGroup struct {
+	bmks   []*Bookmark
+	pinged bool
+	status PingStatus
+	code   int
+	err    error
+}
+
+// Option customizes the behavior of StartWashTillDone.
+type Option func(*washOpts)
+
+type washOpts struct {
+	cleaned   io.Writer // destination of cleaned bookmark file, if any
+	whitelist Whitelist // URLs to keep without pinging
+	offline   bool      // skip network requests
+	prior     map[string]PingStatus
+	sinks     map[PingStatus]io.Writer // extra destinations of wash result, one per status
+	format    Format                   // format of input
+	walk      WalkOptions
+	recheck   map[PingStatus]bool // statuses of previous results to check again
+	canon     CanonOptions
+	dups      io.Writer // destination of duplicate bookmark report, if any
+	hostLimit int
+	hostDelay time.Duration
+	backoff   time.Duration // cap of how long a host asking to back off is backed off
+	attempts  uint          // max requests per HTTP method to ping a URL
+	retryBase time.Duration // delay before the first retry of a request
+	grace     time.Duration // max time to wait for pings in flight once interrupted
+	summary   io.Writer     // destination of summary of interrupted wash, if any
+}
+
+// DefaultGracePeriod is the default max time to wait for pings in flight once washing is interrupted.
+const DefaultGracePeriod = 10 * time.Second
+
+// WithCleanedOutput has StartWashTillDone write the alive bookmarks to w in the same format and structure as
+// input, once washing is done.
+func WithCleanedOutput(w io.Writer) Option {
+	return func(o *washOpts) { o.cleaned = w }
+}
+
+// WithWhitelist has StartWashTillDone consider URLs in wl alive without pinging them. wl should be canonicalized
+// with the options given to WithCanonOptions.
+func WithWhitelist(wl Whitelist) Option {
+	return func(o *washOpts) { o.whitelist = wl }
+}
+
+// WithSkipNetwork has StartWashTillDone issue no network request at all, and decide URL statuses merely with the
+// whitelist and prior, the statuses known from previous runs. Any other URL is considered Unknown. prior should be
+// keyed by URL canonicalized with the options given to WithCanonOptions.
+func WithSkipNetwork(prior map[string]PingStatus) Option {
+	return func(o *washOpts) {
+		o.offline = true
+		o.prior = prior
+	}
+}
+
+// WithStatusOutput has StartWashTillDone additionally write the wash result of URLs in given status to w.
+func WithStatusOutput(status PingStatus, w io.Writer) Option {
+	return func(o *washOpts) {
+		if o.sinks == nil {
+			o.sinks = map[PingStatus]io.Writer{}
+		}
+		o.sinks[status] = w
+	}
+}
+
+// WithFormat has StartWashTillDone walk input in format f. Input format is detected from input by default.
+func WithFormat(f Format) Option {
+	return func(o *washOpts) { o.format = f }
+}
+
+// WithStrict has StartWashTillDone abort on malformed bookmark, instead of tolerating it with warnings.
+func WithStrict() Option {
+	return func(o *washOpts) { o.walk.Strict = true }
+}
+
+// WithRecheck has StartWashTillDone take the wash result previously output by mwsh as input, and check again only
+// the URLs previously in given statuses. Previous results of the other URLs are carried through unchanged.
+func WithRecheck(statuses ...PingStatus) Option {
+	return func(o *washOpts) {
+		o.recheck = map[PingStatus]bool{}
+		for _, s := range statuses {
+			o.recheck[s] = true
+		}
+	}
+}
+
+// WithCanonOptions has StartWashTillDone canonicalize URLs with o, which decides the URLs considered the same.
+func WithCanonOptions(o CanonOptions) Option {
+	return func(wo *washOpts) { wo.canon = o }
+}
+
+// WithDuplicateReport has StartWashTillDone write the groups of bookmarks sharing the same canonical URL to w, once
+// washing is done.
+func WithDuplicateReport(w io.Writer) Option {
+	return func(o *washOpts) { o.dups = w }
+}
+
+// WithHostLimits has StartWashTillDone ping at most limit URLs of the same host at a time, and start pinging URLs
+// of the same host at least delay apart from each other. Zero limit means no limit.
+func WithHostLimits(limit int, delay time.Duration) Option {
+	return func(o *washOpts) { o.hostLimit, o.hostDelay = limit, delay }
+}
+
+// WithMaxBackoff has StartWashTillDone back off a host for at most d when it responds 429 Too Many Requests or 503
+// Service Unavailable, and give up a URL once it has waited out d of such backoffs. Zero d means no cap.
+func WithMaxBackoff(d time.Duration) Option {
+	return func(o *washOpts) { o.backoff = d }
+}
+
+// WithRetries has StartWashTillDone make at most attempts requests with each HTTP method to ping a URL, waiting
+// delay before the first retry and doubling it on each of the following ones.
+func WithRetries(attempts uint, delay time.Duration) Option {
+	return func(o *washOpts) { o.attempts, o.retryBase = attempts, delay }
+}
+
+// WithGracePeriod has StartWashTillDone wait at most d for pings in flight once interrupted by signal.
+func WithGracePeriod(d time.Duration) Option {
+	return func(o *washOpts) { o.grace = d }
+}
+
+// WithSummary has StartWashTillDone write to w how many bookmarks are checked and not, if interrupted by signal.
+func WithSummary(w io.Writer) Option {
+	return func(o *washOpts) { o.summary = w }
+}
+
+// Source is an input of bookmarks to wash.
+type Source struct {
+	Name    string    // name of input, e.g. file path, which bookmarks of multiple inputs are attributed to
+	R       io.Reader // content of input
+	Cleaned io.Writer // destination of cleaned bookmarks of input, if any
 }
 
 // StartWashTillDone creates the washer with in and doer then starts it, piping the wash result to out.
-// It exits until it either finishes iterating the washer or receives signals from OS
-func StartWashTillDone(in io.Reader, out io.Writer, doer Doer, cquota int, log *zap.SugaredLogger) {
-	walker := NewNetscapeWalker(in, log)
+// It exits until it either finishes iterating the washer, or ctx is done. On the first SIGINT or SIGTERM, it stops
+// walking and waits a grace period for pings in flight, then writes what is washed so far as if washing is done.
+// On the second one, it exits right away.
+func StartWashTillDone(ctx context.Context, in io.Reader, out io.Writer, doer Doer, cquota int,
+	log *zap.SugaredLogger, opts ...Option) {
+	StartWashSourcesTillDone(ctx, []Source{{R: in}}, out, doer, cquota, log, opts...)
+}
+
+// StartWashSourcesTillDone is StartWashTillDone walking multiple sources concurrently through one washer. Each
+// canonical URL is pinged once no matter how many bookmarks of the sources hold it. With multiple sources, each
+// result is attributed to its source and the cleaned bookmarks are written per source.
+func StartWashSourcesTillDone(ctx context.Context, srcs []Source, out io.Writer, doer Doer, cquota int,
+	log *zap.SugaredLogger, opts ...Option) {
+	o := &washOpts{
+		format:    FormatAuto,
+		backoff:   DefaultMaxBackoff,
+		attempts:  DefaultAttempts,
+		retryBase: DefaultRetryDelay,
+		grace:     DefaultGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(srcs) == 1 && srcs[0].Cleaned == nil {
+		srcs[0].Cleaned = o.cleaned
+	}
+	var prior map[string]Record
+	if o.recheck != nil {
+		prior = map[string]Record{}
+		if o.format == FormatAuto {
+			o.format = FormatLines
+		}
+	}
+	var walked []Source
+	var walkers []Walker
+	for _, src := range srcs {
+		if o.recheck != nil {
+			var err error
+			if src.R, err = loadPrior(src.R, prior, o.canon); err != nil {
+				log.Errorw("failed to load previous results", "source", src.Name, "error", err)
+				continue
+			}
+		}
+		w, err := NewWalker(o.format, src.R, log, o.walk)
+		if err != nil {
+			log.Errorw("failed to create bookmark walker", "source", src.Name, "error", err)
+			continue
+		}
+		walked, walkers = append(walked, src), append(walkers, w)
+	}
+	if len(walkers) == 0 {
+		return
+	}
+	walker := walkers[0]
+	if len(srcs) > 1 {
+		names := make([]string, len(walked))
+		for i, src := range walked {
+			names[i] = src.Name
+		}
+		walker = NewMultiWalker(names, walkers, log)
+	}
 	defer walker.Stop()
-	pinger := NewHTTPinger(doer, log)
+	var pinger Pinger
+	if o.offline {
+		pinger = &LookupPinger{Statuses: o.prior, Canon: o.canon}
+	} else {
+		hp := NewHTTPinger(doer, log)
+		hp.MaxBackoff = o.backoff
+		hp.Attempts, hp.RetryDelay = o.attempts, o.retryBase
+		pinger = hp
+	}
+	if o.recheck != nil {
+		pinger = &RecheckPinger{Prior: prior, Canon: o.canon, Recheck: o.recheck, Pinger: pinger}
+	}
+	if len(o.whitelist) > 0 {
+		pinger = &WhitelistPinger{Whitelist: o.whitelist, Canon: o.canon, Pinger: pinger}
+	}
 	washer := NewWasher(walker, pinger, log, cquota)
+	washer.Canon = o.canon
+	washer.HostLimit, washer.HostDelay = o.hostLimit, o.hostDelay
 	sigs := make(chan os.Signal, 2)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
 	washed, done := make(chan Result), make(chan struct{})
 	go func() {
 		defer close(washed)
 		for {
-			bmk, err := washer.Next()
-			if err == io.EOF {
+			bmk, err := washer.Next(ctx)
+			if err == io.EOF || ctx.Err() != nil {
 				log.Debug("finished iterating washer")
 				return
 			}
@@ -53,28 +281,99 @@ func StartWashTillDone(in io.Reader, out io.Writer, doer Doer, cquota int, log *
 	sb := &strings.Builder{}
 	defer close(done)
 	defer washer.Stop()
+	// grace fires once interrupted for long enough, when it's time to abandon pings in flight
+	var interrupted bool
+	var grace <-chan time.Time
+	checked := map[PingStatus]int{}
 	for {
 		select {
 		case r, ok := <-washed:
 			if !ok {
 				log.Debug("wash done")
+				// walkers are interrupted in the middle of walk, and keep changing till they exit
+				if interrupted && !stopWalkers(ctx, walker, o.grace, sigs, log) {
+					return
+				}
+				for i, src := range walked {
+					writeCleaned(src.Cleaned, walkers[i], log)
+				}
+				if o.dups != nil {
+					writeDuplicates(o.dups, washer.Duplicates())
+				}
+				if interrupted && o.summary != nil {
+					writeSummary(o.summary, checked, washer.Walked())
+				}
 				return
 			}
-			fmt.Fprintf(sb, "%s\t%s", r.B.Status, r.B.URL)
-			if r.E != nil {
-				fmt.Fprintf(sb, "\t%s", r.E)
+			if r.B == nil {
+				log.Errorw("failed to walk bookmarks", "error", r.E)
+				continue
+			}
+			checked[r.B.Status]++
+			writeRecord(sb, r.B, r.E)
+			fmt.Fprint(out, sb.String())
+			if sink, ok := o.sinks[r.B.Status]; ok {
+				fmt.Fprint(sink, sb.String())
 			}
-			fmt.Fprintln(out, sb.String())
 		case s := <-sigs:
-			log.Debugw("received system signal. Exit", "signal", s)
+			if interrupted {
+				log.Infow("received system signal again. Exit", "signal", s)
+				return
+			}
+			log.Infow("received system signal. Finishing pings in flight; signal again to exit right away",
+				"signal", s, "grace", o.grace)
+			interrupted = true
+			timer := time.NewTimer(o.grace)
+			defer timer.Stop()
+			grace = timer.C
+			washer.Drain()
+		case <-grace:
+			log.Warnw("pings in flight are not done within grace period. Abandon them", "grace", o.grace)
+			washer.Stop()
+		case <-ctx.Done():
+			log.Debugw("context done. Exit", "error", ctx.Err())
 			return
 		}
 		sb.Reset()
 	}
 }
 
-// NewWasher creates a new Washer value. Specify cquota to limit the max concurrency Washer can consume.
+// stopWalkers stops walker and waits at most timeout till it exits, so that the bookmarks it keeps can be written
+// out. It returns false if ctx is done or another signal arrives on sigs before then.
+func stopWalkers(ctx context.Context, walker Walker, timeout time.Duration, sigs <-chan os.Signal,
+	log *zap.SugaredLogger) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	go func() {
+		select {
+		case s := <-sigs:
+			log.Infow("received system signal again. Exit", "signal", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := exhaust(ctx, walker); err != nil {
+		log.Warnw("walking is not stopped in time. Skip writing cleaned output", "error", err)
+		return false
+	}
+	return true
+}
+
+// writeSummary writes to w how many bookmarks are checked and not, of an interrupted wash.
+func writeSummary(w io.Writer, checked map[PingStatus]int, walked int) {
+	total := 0
+	for _, n := range checked {
+		total += n
+	}
+	fmt.Fprintf(w, "Interrupted. Checked %d bookmark(s): %d alive, %d dead, %d unknown.\n", total, checked[Alive],
+		checked[Dead], checked[Unknown])
+	fmt.Fprintf(w, "Not checked: %d walked bookmark(s), plus those not walked yet.\n", walked-total)
+}
+
+// NewWasher creates a new Washer value. Specify cquota to limit the max concurrency of pings over the network.
 func NewWasher(walker Walker, pinger Pinger, log *zap.SugaredLogger, cquota int) *Washer {
+	ctx, cancel := context.WithCancel(context.Background())
+	wctx, drain := context.WithCancel(ctx)
 	return &Washer{
 		walker: walker,
 		pinger: pinger,
@@ -82,55 +381,72 @@ func NewWasher(walker Walker, pinger Pinger, log *zap.SugaredLogger, cquota int)
 		walked: make(chan *Bookmark),
 		washed: make(chan *Result),
 		cquota: make(chan struct{}, cquota),
-		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+		wctx:   wctx,
+		drain:  drain,
+		groups: map[string]*urlGroup{},
 	}
 }
 
 // Next returns the next bookmark whose liveness had been checked, aka "washed" by washer. It returns io.EOF
-// when there is no more bookmark left, or a non-EOF error when washer encounter any during washing.
-func (w *Washer) Next() (*Bookmark, error) {
+// when there is no more bookmark left, ctx.Err() if ctx is done before the next bookmark is washed, or a non-EOF
+// error when washer encounter any during washing. ctx bounds the wait of this call only; call Stop to abandon
+// washing as a whole.
+func (w *Washer) Next(ctx context.Context) (*Bookmark, error) {
 	if !w.started {
 		w.log.Debug("start washing")
 		go w.wash()
 		w.started = true
 	}
-	res, ok := <-w.washed
-	if !ok {
-		return nil, io.EOF
+	select {
+	case res, ok := <-w.washed:
+		if !ok {
+			return nil, io.EOF
+		}
+		return res.B, res.E
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	return res.B, res.E
 }
 
-// Stop stops washer. Consecutively calling Next() after calling Stop() *eventually* returns io.EOF
-func (w *Washer) Stop() { close(w.done) }
+// Stop stops washer, cancelling the walk and pings in flight. Consecutively calling Next() after calling Stop()
+// *eventually* returns io.EOF
+func (w *Washer) Stop() { w.cancel() }
+
+// Drain stops washer from walking more bookmarks and starting more pings, while the pings in flight are still
+// done. Next() returns io.EOF once their bookmarks are washed.
+func (w *Washer) Drain() { w.drain() }
 
 func (w *Washer) wash() {
 	var wkerr error
 	var wg sync.WaitGroup
+	hosts := newHostGates(w.HostLimit, w.HostDelay)
 	defer func() {
-		// wait till all goroutines sending washed bookmarks exit
+		// wait till all goroutines fetching and sending bookmarks exit, which is prompt once washer is stopped
 		wg.Wait()
-		if wkerr != nil {
+		if wkerr != nil && w.wctx.Err() == nil {
 			select {
 			case w.washed <- &Result{B: nil, E: wkerr}:
-			case <-w.done:
-				return
+			case <-w.ctx.Done():
 			}
 		}
 		close(w.washed)
 	}()
 	// fetch bookmarks to process from walker in async
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		defer close(w.walked)
 		for {
 			var bmk *Bookmark
-			bmk, wkerr = w.walker.Next()
+			bmk, wkerr = w.walker.Next(w.wctx)
 			if wkerr != nil {
 				return
 			}
 			select {
 			case w.walked <- bmk:
-			case <-w.done:
+			case <-w.wctx.Done():
 				return
 			}
 		}
@@ -141,25 +457,170 @@ func (w *Washer) wash() {
 			if !ok {
 				return
 			}
+			key := CanonicalURL(bmk.URL, w.Canon)
+			w.mu.Lock()
+			g, seen := w.groups[key]
+			if !seen {
+				g = &urlGroup{}
+				w.groups[key] = g
+			}
+			g.bmks = append(g.bmks, bmk)
+			pinged := g.pinged
+			w.mu.Unlock()
+			if seen && !pinged {
+				// bookmark will be washed along with the others of its group, once the ping is done
+				continue
+			}
 			// wash fetched bookmark in async
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				select {
-				case w.cquota <- struct{}{}:
-					defer func() { <-w.cquota }()
-				case <-w.done:
+				if pinged {
+					w.emit([]*Bookmark{bmk}, g.status, g.code, g.err)
+					return
+				}
+				// pings not started yet are abandoned once drained, leaving their bookmarks not checked. Pinger
+				// acquires the slot only if it goes over the network, so that URLs decided without network request
+				// are not held back by the host limits.
+				if w.wctx.Err() != nil {
 					return
 				}
-				status, err := w.pinger.Ping(bmk.URL)
-				bmk.Status = status
-				select {
-				case w.washed <- &Result{B: bmk, E: err}:
-				case <-w.done:
+				slot := &pingSlot{url: bmk.URL, hosts: hosts, cquota: w.cquota, start: w.wctx}
+				status, code, err := w.pinger.Ping(withPingSlot(w.ctx, slot), bmk.URL)
+				if err == errNotStarted {
+					return
 				}
+				w.mu.Lock()
+				g.pinged, g.status, g.code, g.err = true, status, code, err
+				bmks := append([]*Bookmark(nil), g.bmks...)
+				w.mu.Unlock()
+				w.emit(bmks, status, code, err)
 			}()
-		case <-w.done:
+		case <-w.ctx.Done():
 			return
 		}
 	}
 }
+
+// emit sends bmks washed with given ping result. It returns early if washer is stopped.
+func (w *Washer) emit(bmks []*Bookmark, status PingStatus, code int, err error) {
+	if w.ctx.Err() != nil {
+		// ping may be cut short by the stop, whose result is not worth sending
+		return
+	}
+	for _, b := range bmks {
+		b.Status, b.Code = status, code
+		select {
+		case w.washed <- &Result{B: b, E: err}:
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// Walked returns the number of bookmarks walked so far.
+func (w *Washer) Walked() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, g := range w.groups {
+		n += len(g.bmks)
+	}
+	return n
+}
+
+// Duplicates returns groups of bookmarks sharing the same canonical URL, keyed by the canonical URL. It should be
+// called only after washer is exhausted.
+func (w *Washer) Duplicates() map[string][]*Bookmark {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dups := map[string][]*Bookmark{}
+	for key, g := range w.groups {
+		if len(g.bmks) > 1 {
+			dups[key] = g.bmks
+		}
+	}
+	return dups
+}
+
+// writeDuplicates writes duplicate bookmarks in dups to w, one per line in form of tab-separated columns
+// "<canonical url> <url> <folder>", grouped by the canonical URL. Like writeRecord, unavailable folder is written as
+// "-" and bookmark attributed to a source comes with an extra trailing "<source>" column.
+func writeDuplicates(w io.Writer, dups map[string][]*Bookmark) {
+	keys := make([]string, 0, len(dups))
+	for k := range dups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, b := range dups[k] {
+			folder := "-"
+			if len(b.Folder) > 0 {
+				folder = strings.Join(b.Folder, "/")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s", k, b.URL, folder)
+			if b.Source != "" {
+				fmt.Fprintf(w, "\t%s", b.Source)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// writeRecord writes the wash result of b to w as a line of tab-separated columns "<status> <url> <respCode>
+// <error> <folder> <description>", where an unavailable column is written as "-", folder path is joined by "/" and
+// whitespaces in description are collapsed into single spaces. Bookmark attributed to a source comes with an extra
+// trailing "<source>" column.
+func writeRecord(w io.Writer, b *Bookmark, err error) {
+	code, reason, folder, desc := "-", "-", "-", "-"
+	if b.Code != 0 {
+		code = strconv.Itoa(b.Code)
+	}
+	if err != nil {
+		reason = string(reasonOf(err))
+	}
+	if len(b.Folder) > 0 {
+		folder = strings.Join(b.Folder, "/")
+	}
+	if d := collapseSpace(b.Description); d != "" {
+		desc = d
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s", b.Status, b.URL, code, reason, folder, desc)
+	if b.Source != "" {
+		fmt.Fprintf(w, "\t%s", b.Source)
+	}
+	fmt.Fprintln(w)
+}
+
+// loadPrior loads the previous results held by r into prior, keyed by URL canonicalized with o. It returns a
+// reader yielding the same content as r, which should be used in place of r afterwards.
+func loadPrior(r io.Reader, prior map[string]Record, o CanonOptions) (io.Reader, error) {
+	// input is read twice, for the previous results and the URLs to walk
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	recs, err := LoadRecords(bytes.NewReader(b), o)
+	if err != nil {
+		return nil, err
+	}
+	for url, rec := range recs {
+		prior[url] = rec
+	}
+	return bytes.NewReader(b), nil
+}
+
+// writeCleaned writes cleaned bookmarks walked by walker to out, if out is given and walker supports doing so.
+func writeCleaned(out io.Writer, walker Walker, log *zap.SugaredLogger) {
+	if out == nil {
+		return
+	}
+	c, ok := walker.(Cleaner)
+	if !ok {
+		log.Warn("cleaned output is not supported for the input format")
+		return
+	}
+	if err := c.WriteCleaned(out); err != nil {
+		log.Errorw("failed to write cleaned bookmarks", "error", err)
+	}
+}
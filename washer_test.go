@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	urlpkg "net/url"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -35,9 +38,9 @@ func TestWasher(t *testing.T) {
 			}(),
 			pmock: func() *pingerMock {
 				m := &pingerMock{}
-				m.On("Ping", "https://foo").Return(Alive, nil)
-				m.On("Ping", "https://bar").Return(Dead, nil)
-				m.On("Ping", "https://qux").Return(Unknown, nil)
+				m.On("Ping", "https://foo").Return(Alive, 0, nil)
+				m.On("Ping", "https://bar").Return(Dead, 0, nil)
+				m.On("Ping", "https://qux").Return(Unknown, 0, nil)
 				return m
 			}(),
 			expTuple: []Result{
@@ -66,7 +69,7 @@ func TestWasher(t *testing.T) {
 			}(),
 			pmock: func() *pingerMock {
 				m := &pingerMock{}
-				m.On("Ping", "https://foo").Return(Alive, nil)
+				m.On("Ping", "https://foo").Return(Alive, 0, nil)
 				return m
 			}(),
 			expTuple: []Result{
@@ -86,9 +89,9 @@ func TestWasher(t *testing.T) {
 			}(),
 			pmock: func() *pingerMock {
 				m := &pingerMock{}
-				m.On("Ping", "https://foo").Return(Alive, nil)
-				m.On("Ping", "https://bar").Return(Unknown, errors.New("poom!"))
-				m.On("Ping", "https://qux").Return(Dead, nil)
+				m.On("Ping", "https://foo").Return(Alive, 0, nil)
+				m.On("Ping", "https://bar").Return(Unknown, 0, errors.New("poom!"))
+				m.On("Ping", "https://qux").Return(Dead, 0, nil)
 				return m
 			}(),
 			expTuple: []Result{
@@ -97,6 +100,32 @@ func TestWasher(t *testing.T) {
 				{&Bookmark{URL: "https://qux", Status: Dead}, nil},
 			},
 		},
+		{
+			name: "Duplicates",
+			wmock: func() *walkerMock {
+				m := &walkerMock{}
+				m.On("Next").Return(&Bookmark{URL: "https://foo", Title: "1"}, nil).Once()
+				m.On("Next").Return(&Bookmark{URL: "https://bar"}, nil).Once()
+				m.On("Next").Return(&Bookmark{URL: "HTTPS://FOO:443/", Title: "2"}, nil).Once()
+				m.On("Next").Return(&Bookmark{URL: "https://foo", Title: "3"}, nil).Once()
+				m.On("Next").Return((*Bookmark)(nil), io.EOF).Once()
+				return m
+			}(),
+			pmock: func() *pingerMock {
+				m := &pingerMock{}
+				// pinged once for all bookmarks of the same canonical URL
+				m.On("Ping", mock.MatchedBy(func(url string) bool { return CanonicalURL(url, CanonOptions{}) == "https://foo/" })).
+					Return(Dead, 410, nil).Once()
+				m.On("Ping", "https://bar").Return(Alive, 200, nil).Once()
+				return m
+			}(),
+			expTuple: []Result{
+				{&Bookmark{URL: "https://foo", Title: "1", Status: Dead, Code: 410}, nil},
+				{&Bookmark{URL: "https://bar", Status: Alive, Code: 200}, nil},
+				{&Bookmark{URL: "HTTPS://FOO:443/", Title: "2", Status: Dead, Code: 410}, nil},
+				{&Bookmark{URL: "https://foo", Title: "3", Status: Dead, Code: 410}, nil},
+			},
+		},
 	}
 	log := genTstLogger()
 	for _, c := range tcs {
@@ -107,7 +136,7 @@ func TestWasher(t *testing.T) {
 			defer washer.Stop()
 			actual := []Result{}
 			for {
-				b, err := washer.Next()
+				b, err := washer.Next(context.Background())
 				if err == io.EOF {
 					break
 				}
@@ -120,7 +149,7 @@ func TestWasher(t *testing.T) {
 			for _, tp := range c.expTuple {
 				assert.Contains(t, actual, tp)
 			}
-			_, err := washer.Next()
+			_, err := washer.Next(context.Background())
 			assert.Equal(t, err, io.EOF, "calling Next() after exhausing the washer should have return io.EOF")
 		})
 	}
@@ -131,7 +160,7 @@ func TestWasherStopEarly(t *testing.T) {
 	wmock := &walkerMock{}
 	wmock.On("Next").Return(&Bookmark{URL: fmt.Sprintf("https://foo%d", rand.Intn(1024))}, nil)
 	pmock := &pingerMock{}
-	pmock.On("Ping", mock.Anything).Return(Alive, nil)
+	pmock.On("Ping", mock.Anything).Return(Alive, 0, nil)
 	log := genTstLogger()
 	washer := NewWasher(wmock, pmock, log, 2)
 	// start iterating washer
@@ -139,7 +168,7 @@ func TestWasherStopEarly(t *testing.T) {
 	go func() {
 		defer close(done)
 		for {
-			b, err := washer.Next()
+			b, err := washer.Next(context.Background())
 			if !started {
 				started = true
 				close(start)
@@ -163,6 +192,103 @@ func TestWasherStopEarly(t *testing.T) {
 	}
 }
 
+// blockingPinger pings till ctx is done.
+type blockingPinger struct {
+	pinging chan struct{}
+}
+
+func (p *blockingPinger) Ping(ctx context.Context, url string) (PingStatus, int, error) {
+	p.pinging <- struct{}{}
+	<-ctx.Done()
+	return Unknown, 0, ctx.Err()
+}
+
+func TestWasherStopCancelsPings(t *testing.T) {
+	wmock := &walkerMock{}
+	wmock.On("Next").Return(&Bookmark{URL: "https://foo"}, nil).Once()
+	wmock.On("Next").Return((*Bookmark)(nil), io.EOF).Once()
+	p := &blockingPinger{pinging: make(chan struct{})}
+	washer := NewWasher(wmock, p, genTstLogger(), 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, err := washer.Next(context.Background()); err == io.EOF {
+				return
+			}
+		}
+	}()
+	<-p.pinging
+	washer.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("washer should have cancelled the ping in flight on stop")
+	}
+}
+
+func TestWasherNextContextDone(t *testing.T) {
+	wmock := &walkerMock{}
+	wmock.On("Next").Return(&Bookmark{URL: "https://foo"}, nil).Once()
+	wmock.On("Next").Return((*Bookmark)(nil), io.EOF).Once()
+	p := &blockingPinger{pinging: make(chan struct{}, 1)}
+	washer := NewWasher(wmock, p, genTstLogger(), 2)
+	defer washer.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	b, err := washer.Next(ctx)
+	assert.Nil(t, b)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+// gatedDoer responds once gate is closed, telling each URL requested via requesting.
+type gatedDoer struct {
+	requesting chan string
+	gate       chan struct{}
+}
+
+func (d *gatedDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requesting <- req.URL.String()
+	<-d.gate
+	return genResp(http.StatusOK), nil
+}
+
+func TestWasherDrain(t *testing.T) {
+	wmock := &walkerMock{}
+	for i := 0; i < 3; i++ {
+		wmock.On("Next").Return(&Bookmark{URL: fmt.Sprintf("https://foo.io/%d", i)}, nil).Once()
+	}
+	wmock.On("Next").Return((*Bookmark)(nil), io.EOF)
+	d := &gatedDoer{requesting: make(chan string, 3), gate: make(chan struct{})}
+	washer := NewWasher(wmock, NewHTTPinger(d, genTstLogger()), genTstLogger(), 2)
+	washer.HostLimit = 1
+	defer washer.Stop()
+	washed := make(chan *Bookmark, 3)
+	go func() {
+		defer close(washed)
+		for {
+			b, err := washer.Next(context.Background())
+			if err == io.EOF {
+				return
+			}
+			washed <- b
+		}
+	}()
+	<-d.requesting
+	for washer.Walked() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	// the other pings wait for the host, and should not be started once drained
+	washer.Drain()
+	close(d.gate)
+	var bs []*Bookmark
+	for b := range washed {
+		bs = append(bs, b)
+	}
+	assert.Len(t, bs, 1, "only the ping in flight should have been done")
+	assert.Empty(t, d.requesting)
+}
+
 func TestStartWashTillDone(t *testing.T) {
 	tcs := []struct {
 		name      string
@@ -213,8 +339,13 @@ func TestStartWashTillDone(t *testing.T) {
 				assert.Nil(t, err, "readall from empty buffer should have succeeded")
 				// so that it is easier to debug than peeking raw bytes
 				output := string(b)
-				for _, name := range []string{"bar", "qux", "bee", "foo"} {
-					rec := fmt.Sprintf("dead\thttps://%s.io\tGone\n", name)
+				for name, folder := range map[string]string{
+					"bar": "FooDir",
+					"qux": "FooDir/BarDir",
+					"bee": "FooDir/BarDir/Qux Dir",
+					"foo": "FooDir/BarDir/Qux Dir",
+				} {
+					rec := fmt.Sprintf("dead\thttps://%s.io\t410\thttp_4xx\t%s\t-\n", name, folder)
 					assert.Contains(t, output, rec)
 				}
 			},
@@ -226,13 +357,129 @@ func TestStartWashTillDone(t *testing.T) {
 		c := c
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
-			StartWashTillDone(c.in, c.out, c.dmock, cquota, log)
+			StartWashTillDone(context.Background(), c.in, c.out, c.dmock, cquota, log)
 			c.dmock.AssertExpectations(t)
 			c.verifyOut(t, c.out)
 		})
 	}
 }
 
+func TestStartWashTillDone_CleanedOutput(t *testing.T) {
+	in := bytes.NewReader([]byte(`<TITLE>Bookmarks</TITLE>
+<DL><p>
+    <DT><H3 ADD_DATE="1512790922">FooDir</H3>
+    <DL><p>
+        <DT><A HREF="https://bar.io" ADD_DATE="1515361177">Bar</A>
+        <DT><A HREF="https://qux.io" ADD_DATE="1515361177">Qux</A>
+    </DL><p>
+</DL><p>
+`))
+	dmock := &doerMock{}
+	dmock.On("Do", mock.MatchedBy(func(req *http.Request) bool { return req.URL.Host == "bar.io" })).
+		Return(genResp(http.StatusOK), nil)
+	dmock.On("Do", mock.MatchedBy(func(req *http.Request) bool { return req.URL.Host == "qux.io" })).
+		Return(genResp(http.StatusGone), nil)
+	cleaned := &bytes.Buffer{}
+	StartWashTillDone(context.Background(), in, ioutil.Discard, dmock, 2, genTstLogger(), WithCleanedOutput(cleaned))
+	dmock.AssertExpectations(t)
+	output := cleaned.String()
+	assert.Contains(t, output, `<DT><H3 ADD_DATE="1512790922">FooDir</H3>`)
+	assert.Contains(t, output, `<DT><A HREF="https://bar.io" ADD_DATE="1515361177">Bar</A>`)
+	assert.NotContains(t, output, "qux.io")
+}
+
+func TestStartWashTillDone_MissingHref(t *testing.T) {
+	in := bytes.NewReader([]byte(`<DL><p>
+    <DT><A HREF="https://foo.io" ADD_DATE="1515361177">Foo</A>
+    <DT><A ADD_DATE="1515361177">Nowhere</A>
+</DL><p>
+`))
+	dmock := &doerMock{}
+	dmock.On("Do", mock.MatchedBy(func(req *http.Request) bool { return req.URL.Host == "foo.io" })).
+		Return(genResp(http.StatusOK), nil).Once()
+	out, cleaned := &bytes.Buffer{}, &bytes.Buffer{}
+	StartWashTillDone(context.Background(), in, out, dmock, 2, genTstLogger(), WithCleanedOutput(cleaned))
+	dmock.AssertExpectations(t)
+	assert.Equal(t, "alive\thttps://foo.io\t200\t-\t-\t-\n", out.String())
+	assert.NotContains(t, cleaned.String(), "Nowhere")
+}
+
+func TestStartWashTillDone_StatusOutput(t *testing.T) {
+	in := bytes.NewReader([]byte(`<DL><p>
+    <DT><A HREF="https://foo.io" ADD_DATE="1515361177">Foo</A>
+    <DT><A HREF="https://bar.io" ADD_DATE="1515361177">Bar</A>
+    <DT><A HREF="https://qux.io" ADD_DATE="1515361177">Qux</A>
+</DL><p>
+`))
+	dmock := &doerMock{}
+	for host, code := range map[string]int{"foo.io": http.StatusOK, "bar.io": http.StatusGone, "qux.io": http.StatusNotFound} {
+		host := host
+		dmock.On("Do", mock.MatchedBy(func(req *http.Request) bool { return req.URL.Host == host })).
+			Return(genResp(code), nil)
+	}
+	out, alive, dead := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	StartWashTillDone(context.Background(), in, out, dmock, 2, genTstLogger(), WithStatusOutput(Alive, alive), WithStatusOutput(Dead, dead))
+	dmock.AssertExpectations(t)
+	assert.Equal(t, "alive\thttps://foo.io\t200\t-\t-\t-\n", alive.String())
+	assert.Equal(t, "dead\thttps://bar.io\t410\thttp_4xx\t-\t-\n", dead.String())
+	for _, rec := range []string{alive.String(), dead.String(), "unknown\thttps://qux.io\t404\thttp_4xx\t-\t-\n"} {
+		assert.Contains(t, out.String(), rec)
+	}
+}
+
+func TestStartWashSourcesTillDone(t *testing.T) {
+	html := `<DL><p>
+    <DT><A HREF="https://foo.io" ADD_DATE="1515361177">Foo</A>
+    <DT><A HREF="https://bar.io" ADD_DATE="1515361177">Bar</A>
+</DL><p>
+`
+	lines := "https://bar.io/\nhttps://qux.io\n"
+	dmock := &doerMock{}
+	for host, code := range map[string]int{"foo.io": http.StatusOK, "bar.io": http.StatusOK, "qux.io": http.StatusGone} {
+		host := host
+		// each URL is pinged once, even if it appears in multiple sources
+		dmock.On("Do", mock.MatchedBy(func(req *http.Request) bool { return req.URL.Host == host })).
+			Return(genResp(code), nil).Once()
+	}
+	out, cleaned := &bytes.Buffer{}, &bytes.Buffer{}
+	StartWashSourcesTillDone(context.Background(), []Source{
+		{Name: "a.html", R: strings.NewReader(html), Cleaned: cleaned},
+		{Name: "b.txt", R: strings.NewReader(lines)},
+	}, out, dmock, 2, genTstLogger())
+	dmock.AssertExpectations(t)
+	for _, rec := range []string{
+		"alive\thttps://foo.io\t200\t-\t-\t-\ta.html\n",
+		"alive\thttps://bar.io\t200\t-\t-\t-\ta.html\n",
+		"alive\thttps://bar.io/\t200\t-\t-\t-\tb.txt\n",
+		"dead\thttps://qux.io\t410\thttp_4xx\t-\t-\tb.txt\n",
+	} {
+		assert.Contains(t, out.String(), rec)
+	}
+	assert.Contains(t, cleaned.String(), "https://foo.io")
+	assert.Contains(t, cleaned.String(), "https://bar.io")
+}
+
+func TestStartWashTillDone_DuplicateReport(t *testing.T) {
+	in := bytes.NewReader([]byte(`<DL><p>
+    <DT><H3>FooDir</H3>
+    <DL><p>
+        <DT><A HREF="https://foo.io/?utm_source=x#top">Foo</A>
+    </DL><p>
+    <DT><A HREF="HTTPS://FOO.IO">Foo</A>
+    <DT><A HREF="https://bar.io">Bar</A>
+</DL><p>
+`))
+	dmock := &doerMock{}
+	dmock.On("Do", mock.Anything).Return(genResp(http.StatusOK), nil).Twice()
+	out, dups := &bytes.Buffer{}, &bytes.Buffer{}
+	StartWashTillDone(context.Background(), in, out, dmock, 2, genTstLogger(), WithDuplicateReport(dups),
+		WithCanonOptions(CanonOptions{StripTracking: true, StripFragment: true}))
+	dmock.AssertExpectations(t)
+	assert.Equal(t, 3, strings.Count(out.String(), "\n"))
+	assert.Equal(t, "https://foo.io/\thttps://foo.io/?utm_source=x#top\tFooDir\n"+
+		"https://foo.io/\tHTTPS://FOO.IO\t-\n", dups.String())
+}
+
 func TestStartWashTillDoneStopOnSignal(t *testing.T) {
 	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM} {
 		sig := sig
@@ -253,7 +500,7 @@ func TestStartWashTillDoneStopOnSignal(t *testing.T) {
 			aborted := make(chan struct{})
 			go func() {
 				defer close(aborted)
-				StartWashTillDone(in, out, dmock, cquota, log)
+				StartWashTillDone(context.Background(), in, out, dmock, cquota, log)
 			}()
 			<-washstart
 			timeout := time.NewTimer(500 * time.Millisecond)
@@ -270,12 +517,111 @@ func TestStartWashTillDoneStopOnSignal(t *testing.T) {
 	}
 }
 
+// doerFunc adapts a func to Doer.
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// blockingDoer returns a Doer signaling pinging on each request, which blocks till release is closed or the
+// request is cancelled.
+func blockingDoer(pinging chan<- struct{}, release <-chan struct{}) Doer {
+	return doerFunc(func(req *http.Request) (*http.Response, error) {
+		select {
+		case pinging <- struct{}{}:
+		default:
+		}
+		select {
+		case <-release:
+			return genResp(http.StatusOK), nil
+		case <-req.Context().Done():
+			return nil, &urlpkg.Error{Op: req.Method, URL: req.URL.String(), Err: req.Context().Err()}
+		}
+	})
+}
+
+func TestStartWashTillDone_GracefulShutdown(t *testing.T) {
+	pinging, release := make(chan struct{}, 1), make(chan struct{})
+	out, cleaned, summary := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		StartWashTillDone(context.Background(), &infReader{}, out, blockingDoer(pinging, release), 2,
+			genTstLogger(), WithCleanedOutput(cleaned), WithSummary(summary), WithGracePeriod(time.Minute))
+	}()
+	<-pinging
+	syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	// ping in flight completes within grace period
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("StartWashTillDone should have finished once pings in flight are done")
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.NotEmpty(t, lines[0])
+	for _, l := range lines {
+		assert.True(t, strings.HasPrefix(l, "alive\thttps://foo/\t200"), "unexpected result %q", l)
+	}
+	assert.Contains(t, cleaned.String(), `<A HREF="https://foo/"`)
+	assert.Equal(t, fmt.Sprintf("Interrupted. Checked %d bookmark(s): %d alive, 0 dead, 0 unknown.\n", len(lines),
+		len(lines))+"Not checked: 0 walked bookmark(s), plus those not walked yet.\n", summary.String())
+}
+
+func TestStartWashTillDone_GracePeriodElapsed(t *testing.T) {
+	pinging := make(chan struct{}, 1)
+	out, cleaned, summary := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		StartWashTillDone(context.Background(), &infReader{}, out, blockingDoer(pinging, nil), 2,
+			genTstLogger(), WithCleanedOutput(cleaned), WithSummary(summary), WithGracePeriod(100*time.Millisecond))
+	}()
+	<-pinging
+	syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("StartWashTillDone should have abandoned pings in flight once grace period elapsed")
+	}
+	assert.Empty(t, out.String())
+	assert.Contains(t, cleaned.String(), "<DL><p>")
+	assert.NotContains(t, cleaned.String(), "https://foo/")
+	assert.Regexp(t, `^Interrupted. Checked 0 bookmark\(s\): 0 alive, 0 dead, 0 unknown.\n`+
+		`Not checked: [1-9]\d* walked bookmark\(s\), plus those not walked yet.\n$`, summary.String())
+}
+
+func TestStartWashTillDone_SecondSignal(t *testing.T) {
+	pinging := make(chan struct{}, 1)
+	out, cleaned, summary := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		StartWashTillDone(context.Background(), &infReader{}, out, blockingDoer(pinging, nil), 2,
+			genTstLogger(), WithCleanedOutput(cleaned), WithSummary(summary), WithGracePeriod(time.Minute))
+	}()
+	<-pinging
+	syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	time.Sleep(50 * time.Millisecond)
+	syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("StartWashTillDone should have exited right away on the second signal")
+	}
+	assert.Empty(t, out.String())
+	assert.Empty(t, cleaned.String())
+	assert.Empty(t, summary.String())
+}
+
 type walkerMock struct {
 	Walker
 	mock.Mock
 }
 
-func (m *walkerMock) Next() (*Bookmark, error) {
+func (m *walkerMock) Next(ctx context.Context) (*Bookmark, error) {
 	args := m.Called()
 	return args.Get(0).(*Bookmark), args.Error(1)
 }
@@ -289,7 +635,7 @@ type pingerMock struct {
 	mock.Mock
 }
 
-func (m *pingerMock) Ping(url string) (PingStatus, error) {
+func (m *pingerMock) Ping(ctx context.Context, url string) (PingStatus, int, error) {
 	args := m.Called(url)
-	return args.Get(0).(PingStatus), args.Error(1)
+	return args.Get(0).(PingStatus), args.Int(1), args.Error(2)
 }
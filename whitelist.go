@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+)
+
+// Whitelist is a set of URLs which user wants to keep, regardless of their liveness.
+type Whitelist map[string]struct{}
+
+// LoadWhitelist reads whitelist from r, which is a newline-delimited list of URLs. Blank lines are skipped. URLs
+// are canonicalized with o, which should be the options URLs are washed with.
+func LoadWhitelist(r io.Reader, o CanonOptions) (Whitelist, error) {
+	wl := Whitelist{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		wl[CanonicalURL(line, o)] = struct{}{}
+	}
+	return wl, s.Err()
+}
+
+// Contains tells whether url, canonicalized with o, is whitelisted.
+func (wl Whitelist) Contains(url string, o CanonOptions) bool {
+	_, ok := wl[CanonicalURL(url, o)]
+	return ok
+}
+
+// WhitelistPinger considers whitelisted URLs alive without pinging them, and have Pinger ping all the others.
+type WhitelistPinger struct {
+	Whitelist Whitelist
+	Canon     CanonOptions // options Whitelist is canonicalized with
+	Pinger    Pinger
+}
+
+// Ping returns Alive if url is whitelisted, otherwise it returns the result of pinging url with p.Pinger.
+func (p *WhitelistPinger) Ping(ctx context.Context, url string) (PingStatus, int, error) {
+	if p.Whitelist.Contains(url, p.Canon) {
+		return Alive, 0, nil
+	}
+	return p.Pinger.Ping(ctx, url)
+}
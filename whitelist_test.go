@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLoadWhitelist(t *testing.T) {
+	wl, err := LoadWhitelist(strings.NewReader(`https://foo.io/
+  HTTPS://Bar.IO/baz
+
+https://qux.io/Path/?q=1&utm_source=x
+`), CanonOptions{StripTracking: true})
+	assert.Nil(t, err)
+	tcs := []struct {
+		url string
+		exp bool
+	}{
+		{"https://foo.io/", true},
+		{"https://foo.io", true},
+		{"https://FOO.io", true},
+		{"https://bar.io/baz/", true},
+		{"https://bar.io/baz", true},
+		{"https://qux.io/Path?q=1", true},
+		{"https://qux.io:443/Path?q=1", true},
+		{"https://qux.io/Path?utm_medium=y&q=1", true},
+		{"https://qux.io/path?q=1", false},
+		{"http://foo.io/", false},
+		{"https://bee.io/", false},
+	}
+	for _, c := range tcs {
+		assert.Equalf(t, c.exp, wl.Contains(c.url, CanonOptions{StripTracking: true}), "whitelist contains %s", c.url)
+	}
+}
+
+func TestWhitelistPinger(t *testing.T) {
+	pmock := &pingerMock{}
+	pmock.On("Ping", "https://bar.io").Return(Dead, http.StatusGone, errors.New("boom!")).Once()
+	p := &WhitelistPinger{Whitelist: Whitelist{CanonicalURL("https://foo.io", CanonOptions{}): {}}, Pinger: pmock}
+
+	status, code, err := p.Ping(context.Background(), "https://foo.io/")
+	assert.Equal(t, Alive, status)
+	assert.Equal(t, 0, code)
+	assert.Nil(t, err)
+
+	status, code, err = p.Ping(context.Background(), "https://bar.io")
+	assert.Equal(t, Dead, status)
+	assert.Equal(t, http.StatusGone, code)
+	assert.Equal(t, errors.New("boom!"), err)
+	pmock.AssertExpectations(t)
+}
+
+func TestStartWashTillDone_WhitelistCanonical(t *testing.T) {
+	canon := CanonOptions{StripTracking: true}
+	wl, err := LoadWhitelist(strings.NewReader("https://a.io/\n"), canon)
+	assert.Nil(t, err)
+	// whitelisted bookmarks are kept regardless of which one of the same canonical URL is walked first
+	in := strings.NewReader(`<DL><p>
+    <DT><A HREF="https://a.io/?utm_source=x">A</A>
+    <DT><A HREF="https://a.io/">A</A>
+</DL><p>
+`)
+	dmock := &doerMock{}
+	out := &bytes.Buffer{}
+	StartWashTillDone(context.Background(), in, out, dmock, 2, genTstLogger(), WithWhitelist(wl),
+		WithCanonOptions(canon))
+	dmock.AssertNotCalled(t, "Do", mock.Anything)
+	assert.Equal(t, "alive\thttps://a.io/?utm_source=x\t-\t-\t-\t-\n"+
+		"alive\thttps://a.io/\t-\t-\t-\t-\n", out.String())
+}